@@ -0,0 +1,279 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/spi-ca/eighty"
+	"github.com/spi-ca/misc"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	authorizationHeader = "Authorization"
+	bearerPrefix        = "Bearer "
+	// tokenFetchTimeout bounds a token request, which outlives the requests waiting for it
+	tokenFetchTimeout = 30 * time.Second
+)
+
+type (
+	basicAuthTransport struct {
+		next               http.RoundTripper
+		username, password string
+	}
+
+	bearerTransport struct {
+		next  http.RoundTripper
+		token string
+	}
+
+	tokenResponse struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	tokenRenewal struct {
+		done  chan struct{}
+		token string
+		err   error
+	}
+	clientCredentialsTransport struct {
+		next          http.RoundTripper
+		tokenURL      string
+		clientID      string
+		clientSecret  string
+		scopes        []string
+		refreshBefore time.Duration
+
+		lock    sync.Mutex
+		token   string
+		expiry  time.Time
+		renewal *tokenRenewal
+	}
+
+	hmacSigningTransport struct {
+		next          http.RoundTripper
+		keyID         string
+		secret        []byte
+		signedHeaders []string
+	}
+)
+
+// NewBasicAuthTransport returns a http.RoundTripper that adds the basic authorization to every request.
+func NewBasicAuthTransport(next http.RoundTripper, username, password string) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &basicAuthTransport{next: next, username: username, password: password}
+}
+
+func (t *basicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(t.username, t.password)
+	return t.next.RoundTrip(req)
+}
+
+// NewBearerTransport returns a http.RoundTripper that adds the static bearer token to every request.
+func NewBearerTransport(next http.RoundTripper, token string) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &bearerTransport{next: next, token: token}
+}
+
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set(authorizationHeader, bearerPrefix+t.token)
+	return t.next.RoundTrip(req)
+}
+
+// NewClientCredentialsTransport returns a http.RoundTripper that authorizes requests with
+// the OAuth2 client credentials grant.
+// The access token is cached and renewed refreshBefore its expiry,
+// concurrent requests share a single renewal.
+func NewClientCredentialsTransport(
+	next http.RoundTripper,
+	tokenURL string,
+	clientID string,
+	clientSecret string,
+	scopes []string,
+	refreshBefore time.Duration,
+) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &clientCredentialsTransport{
+		next:          next,
+		tokenURL:      tokenURL,
+		clientID:      clientID,
+		clientSecret:  clientSecret,
+		scopes:        scopes,
+		refreshBefore: refreshBefore,
+	}
+}
+
+func (t *clientCredentialsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.currentToken(req.Context())
+	if err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set(authorizationHeader, bearerPrefix+token)
+	res, err := t.next.RoundTrip(req)
+	if err == nil && res.StatusCode == http.StatusUnauthorized {
+		// the token was revoked before its expiry
+		t.invalidate(token)
+	}
+	return res, err
+}
+
+func (t *clientCredentialsTransport) invalidate(token string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.token == token {
+		t.token = ""
+	}
+}
+
+func (t *clientCredentialsTransport) currentToken(ctx context.Context) (string, error) {
+	t.lock.Lock()
+	if len(t.token) > 0 && time.Now().Add(t.refreshBefore).Before(t.expiry) {
+		defer t.lock.Unlock()
+		return t.token, nil
+	}
+	renewal := t.renewal
+	leader := renewal == nil
+	if leader {
+		renewal = &tokenRenewal{done: make(chan struct{})}
+		t.renewal = renewal
+	}
+	t.lock.Unlock()
+
+	if leader {
+		// the fetch is shared, so it must not fail with the context of the request that started it
+		go t.renew(renewal)
+	}
+
+	select {
+	case <-renewal.done:
+		return renewal.token, renewal.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// renew fetches a token for the waiters of the renewal.
+func (t *clientCredentialsTransport) renew(renewal *tokenRenewal) {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenFetchTimeout)
+	defer cancel()
+	token, expiry, err := t.fetchToken(ctx)
+
+	t.lock.Lock()
+	if err == nil {
+		t.token, t.expiry = token, expiry
+	}
+	t.renewal = nil
+	t.lock.Unlock()
+
+	renewal.token, renewal.err = token, err
+	close(renewal.done)
+}
+
+func (t *clientCredentialsTransport) fetchToken(ctx context.Context) (token string, expiry time.Time, err error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(t.scopes) > 0 {
+		form.Set("scope", strings.Join(t.scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return
+	}
+	req.SetBasicAuth(url.QueryEscape(t.clientID), url.QueryEscape(t.clientSecret))
+	req.Header.Set(eighty.ContentTypeHeader, eighty.UrlencodeContentType[0])
+	req.Header.Set(eighty.AcceptHeader, eighty.JsonContentType[0])
+
+	issuedAt := time.Now()
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, DefaultJSONResponseLimit))
+	if err != nil {
+		return
+	} else if res.StatusCode != http.StatusOK {
+		err = newResponseError(res, body)
+		return
+	}
+
+	var parsed tokenResponse
+	if err = misc.JSONCodec.Unmarshal(body, &parsed); err != nil {
+		return
+	} else if len(parsed.AccessToken) == 0 {
+		err = errors.New("token endpoint returned no access token")
+		return
+	}
+	token = parsed.AccessToken
+	if parsed.ExpiresIn > 0 {
+		expiry = issuedAt.Add(time.Duration(parsed.ExpiresIn) * time.Second)
+	} else {
+		// no expiry was announced, keep the token until the server rejects it
+		expiry = issuedAt.Add(100 * 365 * 24 * time.Hour)
+	}
+	return
+}
+
+// NewHMACSigningTransport returns a http.RoundTripper that signs every request with HMAC-SHA256.
+// The signature covers the method, the request URI, the body digest, the signing date
+// and the given extra headers. Use middleware.HMACVerifyFunc to verify it on the server.
+func NewHMACSigningTransport(next http.RoundTripper, keyID string, secret []byte, extraHeaders ...string) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &hmacSigningTransport{
+		next:          next,
+		keyID:         keyID,
+		secret:        secret,
+		signedHeaders: eighty.SignatureHeaders(extraHeaders...),
+	}
+}
+
+func (t *hmacSigningTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req = req.Clone(req.Context())
+	if body != nil {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	}
+	req.Header.Set(eighty.SignatureDateHeader, time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set(eighty.DigestHeader, eighty.BodyDigest(body))
+
+	base := eighty.SignatureBase(req.Method, req.URL.RequestURI(), t.signedHeaders, func(name string) string {
+		if name != "host" {
+			return req.Header.Get(name)
+		} else if len(req.Host) > 0 {
+			// the Host header overrides the URL host
+			return req.Host
+		}
+		return req.URL.Host
+	})
+	req.Header.Set(eighty.SignatureHeader, eighty.FormatSignature(t.keyID, t.signedHeaders, eighty.SignHMAC(t.secret, base)))
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,399 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"github.com/spi-ca/misc"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// Collection of working modes of the Cassette.
+const (
+	// CassetteReplay serves stored interactions and never touches the network.
+	CassetteReplay CassetteMode = iota
+	// CassetteRecord passes requests to the real transport and stores the exchanges.
+	CassetteRecord
+)
+
+const redactedValue = "[REDACTED]"
+
+type (
+	// CassetteMode is a working mode of the Cassette.
+	CassetteMode int
+
+	// RecordedRequest is a serializable form of the http.Request.
+	RecordedRequest struct {
+		Method string      `json:"method"`
+		URL    string      `json:"url"`
+		Header http.Header `json:"header,omitempty"`
+		Body   string      `json:"body,omitempty"`
+	}
+
+	// RecordedResponse is a serializable form of the http.Response.
+	RecordedResponse struct {
+		StatusCode int         `json:"status"`
+		Header     http.Header `json:"header,omitempty"`
+		Body       string      `json:"body,omitempty"`
+	}
+
+	// Interaction is a recorded pair of the request and the response.
+	Interaction struct {
+		Request  RecordedRequest  `json:"request"`
+		Response RecordedResponse `json:"response"`
+	}
+
+	// RequestMatcher reports whether the incoming request matches the recorded one.
+	RequestMatcher func(incoming, recorded *RecordedRequest) bool
+
+	// Redactor rewrites sensitive parts of the interaction before it is stored or matched.
+	Redactor func(interaction *Interaction)
+
+	// Cassette is a http.RoundTripper that records or replays http exchanges.
+	Cassette interface {
+		http.RoundTripper
+		// Mode returns the working mode of the Cassette.
+		Mode() CassetteMode
+		// Interactions returns all stored interactions.
+		Interactions() []Interaction
+		// Unused returns stored interactions that were not replayed yet.
+		Unused() []Interaction
+		// Save writes the stored interactions to the cassette file.
+		Save() error
+	}
+
+	// StubTransport is a programmable http.RoundTripper for table-driven tests.
+	StubTransport interface {
+		http.RoundTripper
+		// On registers a canned response for requests that satisfy the matcher.
+		On(matcher func(*http.Request) bool, statusCode int, header http.Header, body string) StubTransport
+		// OnFunc registers a responder function for requests that satisfy the matcher.
+		OnFunc(matcher func(*http.Request) bool, responder func(*http.Request) (*http.Response, error)) StubTransport
+	}
+
+	// UnmatchedRequestError is returned when the Cassette or the StubTransport has no answer for a request.
+	UnmatchedRequestError struct {
+		Request RecordedRequest
+	}
+
+	cassetteImpl struct {
+		path      string
+		mode      CassetteMode
+		next      http.RoundTripper
+		matcher   RequestMatcher
+		redactors []Redactor
+
+		lock         sync.Mutex
+		interactions []Interaction
+		used         []bool
+	}
+
+	stubRule struct {
+		matcher   func(*http.Request) bool
+		responder func(*http.Request) (*http.Response, error)
+	}
+	stubTransportImpl struct {
+		lock  sync.RWMutex
+		rules []stubRule
+	}
+)
+
+var (
+	// Is the interface compatible with the actual dto
+	_ error = (*UnmatchedRequestError)(nil)
+)
+
+// Error implements the built-in interface type error.
+func (e *UnmatchedRequestError) Error() string {
+	return "no recorded interaction matches the request: " + e.Request.Method + " " + e.Request.URL
+}
+
+// MatchMethodAndURL is a RequestMatcher that compares the method and the full URL.
+func MatchMethodAndURL(incoming, recorded *RecordedRequest) bool {
+	return incoming.Method == recorded.Method && incoming.URL == recorded.URL
+}
+
+// MatchBody is a RequestMatcher that compares the request body.
+func MatchBody(incoming, recorded *RecordedRequest) bool {
+	return incoming.Body == recorded.Body
+}
+
+// MatchHeaders returns a RequestMatcher that compares the given header values.
+func MatchHeaders(names ...string) RequestMatcher {
+	return func(incoming, recorded *RecordedRequest) bool {
+		for _, name := range names {
+			if incoming.Header.Get(name) != recorded.Header.Get(name) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchAll returns a RequestMatcher that is satisfied when all the given matchers are satisfied.
+func MatchAll(matchers ...RequestMatcher) RequestMatcher {
+	return func(incoming, recorded *RecordedRequest) bool {
+		for _, matcher := range matchers {
+			if !matcher(incoming, recorded) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// RedactHeaders returns a Redactor that masks the given request and response header values.
+func RedactHeaders(names ...string) Redactor {
+	return func(interaction *Interaction) {
+		for _, name := range names {
+			for _, header := range []http.Header{interaction.Request.Header, interaction.Response.Header} {
+				if values := header.Values(name); len(values) > 0 {
+					header.Set(name, redactedValue)
+				}
+			}
+		}
+	}
+}
+
+// RedactBody returns a Redactor that replaces matches of the pattern in request and response bodies.
+func RedactBody(pattern *regexp.Regexp, replacement string) Redactor {
+	return func(interaction *Interaction) {
+		interaction.Request.Body = pattern.ReplaceAllString(interaction.Request.Body, replacement)
+		interaction.Response.Body = pattern.ReplaceAllString(interaction.Response.Body, replacement)
+	}
+}
+
+// NewCassette returns a Cassette that works with the file at the given path.
+// In the CassetteReplay mode the file is loaded immediately and every request must match a stored interaction.
+// In the CassetteRecord mode requests are sent through next, or http.DefaultTransport if next is nil.
+// If matcher is nil, MatchMethodAndURL is used.
+func NewCassette(path string, mode CassetteMode, next http.RoundTripper, matcher RequestMatcher, redactors ...Redactor) (Cassette, error) {
+	if matcher == nil {
+		matcher = MatchMethodAndURL
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	c := &cassetteImpl{
+		path:      path,
+		mode:      mode,
+		next:      next,
+		matcher:   matcher,
+		redactors: redactors,
+	}
+	switch mode {
+	case CassetteReplay:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		} else if err = misc.JSONCodec.Unmarshal(data, &c.interactions); err != nil {
+			return nil, err
+		}
+		c.used = make([]bool, len(c.interactions))
+	case CassetteRecord:
+	default:
+		return nil, errors.New("unknown cassette mode: " + strconv.Itoa(int(mode)))
+	}
+	return c, nil
+}
+
+func (c *cassetteImpl) Mode() CassetteMode { return c.mode }
+
+func (c *cassetteImpl) Interactions() []Interaction {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]Interaction(nil), c.interactions...)
+}
+
+func (c *cassetteImpl) Unused() (unused []Interaction) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for i, used := range c.used {
+		if !used {
+			unused = append(unused, c.interactions[i])
+		}
+	}
+	return
+}
+
+func (c *cassetteImpl) Save() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	data, err := misc.JSONCodec.MarshalIndent(c.interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0644)
+}
+
+func (c *cassetteImpl) RoundTrip(req *http.Request) (*http.Response, error) {
+	recordedReq, req, err := recordRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if c.mode == CassetteReplay {
+		closeBody(req)
+		return c.replay(req, recordedReq)
+	}
+
+	res, err := c.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	interaction := Interaction{
+		Request: recordedReq,
+		Response: RecordedResponse{
+			StatusCode: res.StatusCode,
+			Header:     res.Header.Clone(),
+			Body:       string(body),
+		},
+	}
+	c.redact(&interaction)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.interactions = append(c.interactions, interaction)
+	c.used = append(c.used, true)
+	return res, nil
+}
+
+func (c *cassetteImpl) replay(req *http.Request, recordedReq RecordedRequest) (*http.Response, error) {
+	incoming := Interaction{Request: recordedReq}
+	c.redact(&incoming)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for i := range c.interactions {
+		if c.used[i] || !c.matcher(&incoming.Request, &c.interactions[i].Request) {
+			continue
+		}
+		c.used[i] = true
+		recorded := c.interactions[i].Response
+		return newResponse(req, recorded.StatusCode, recorded.Header.Clone(), recorded.Body), nil
+	}
+	return nil, &UnmatchedRequestError{Request: recordedReq}
+}
+
+func (c *cassetteImpl) redact(interaction *Interaction) {
+	for _, redactor := range c.redactors {
+		redactor(interaction)
+	}
+}
+
+// NewStubTransport returns an empty StubTransport.
+// Requests that satisfy none of the registered matchers fail with the UnmatchedRequestError.
+func NewStubTransport() StubTransport {
+	return &stubTransportImpl{}
+}
+
+// StubRoute returns a matcher for the StubTransport that compares the method and the full URL.
+func StubRoute(method, url string) func(*http.Request) bool {
+	return func(req *http.Request) bool {
+		return req.Method == method && req.URL.String() == url
+	}
+}
+
+func (st *stubTransportImpl) On(matcher func(*http.Request) bool, statusCode int, header http.Header, body string) StubTransport {
+	return st.OnFunc(matcher, func(req *http.Request) (*http.Response, error) {
+		return newResponse(req, statusCode, header.Clone(), body), nil
+	})
+}
+
+func (st *stubTransportImpl) OnFunc(matcher func(*http.Request) bool, responder func(*http.Request) (*http.Response, error)) StubTransport {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	st.rules = append(st.rules, stubRule{matcher: matcher, responder: responder})
+	return st
+}
+
+func (st *stubTransportImpl) RoundTrip(req *http.Request) (*http.Response, error) {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	for _, rule := range st.rules {
+		if rule.matcher(req) {
+			return rule.responder(req)
+		}
+	}
+	recordedReq, req, err := recordRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	closeBody(req)
+	return nil, &UnmatchedRequestError{Request: recordedReq}
+}
+
+// recordRequest converts the request into the RecordedRequest and returns the request to send on.
+// The body is read from a GetBody copy when possible, otherwise the returned request is
+// a clone carrying the buffered body, so the fields of the given request are left untouched.
+func recordRequest(req *http.Request) (recorded RecordedRequest, forward *http.Request, err error) {
+	recorded = RecordedRequest{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: req.Header.Clone(),
+	}
+	forward = req
+	if req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+
+	var body []byte
+	if req.GetBody != nil {
+		var copied io.ReadCloser
+		if copied, err = req.GetBody(); err != nil {
+			closeBody(req)
+			return
+		}
+		body, err = io.ReadAll(copied)
+		_ = copied.Close()
+		if err != nil {
+			closeBody(req)
+			return
+		}
+	} else {
+		body, err = io.ReadAll(req.Body)
+		closeBody(req)
+		if err != nil {
+			return
+		}
+		forward = req.Clone(req.Context())
+		forward.Body = io.NopCloser(bytes.NewReader(body))
+		forward.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	}
+	recorded.Body = string(body)
+	return
+}
+
+// closeBody closes the request body, which a RoundTripper must do even when it does not send the request.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
+
+func newResponse(req *http.Request, statusCode int, header http.Header, body string) *http.Response {
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(statusCode) + " " + http.StatusText(statusCode),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(body))),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,155 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCassetteRecordReplay(t *testing.T) {
+	var served int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+		w.Header().Set("X-Session", "session-secret")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"path":"` + r.URL.Path + `","token":"tk-12345"}`))
+	}))
+	defer srv.Close()
+
+	var (
+		path      = filepath.Join(t.TempDir(), "cassette.json")
+		redactors = []Redactor{
+			RedactHeaders("Authorization", "X-Session"),
+			RedactBody(regexp.MustCompile(`tk-[0-9]+`), "tk-redacted"),
+		}
+	)
+	do := func(rt http.RoundTripper, target string) (*http.Response, string, error) {
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer request-secret")
+		res, err := rt.RoundTrip(req)
+		if err != nil {
+			return nil, "", err
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res, string(body), nil
+	}
+
+	recorder, err := NewCassette(path, CassetteRecord, nil, nil, redactors...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, body, err := do(recorder, srv.URL+"/users")
+	if err != nil {
+		t.Fatal(err)
+	} else if res.StatusCode != http.StatusCreated || !strings.Contains(body, "tk-12345") {
+		t.Fatalf("recording changed the live response: %d %s", res.StatusCode, body)
+	} else if err = recorder.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"request-secret", "session-secret", "tk-12345"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("cassette file contains %q", secret)
+		}
+	}
+
+	player, err := NewCassette(path, CassetteReplay, nil, nil, redactors...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, body, err = do(player, srv.URL+"/users")
+	if err != nil {
+		t.Fatal(err)
+	} else if res.StatusCode != http.StatusCreated {
+		t.Errorf("replayed status %d, want %d", res.StatusCode, http.StatusCreated)
+	} else if body != `{"path":"/users","token":"tk-redacted"}` {
+		t.Errorf("replayed body %s", body)
+	} else if value := res.Header.Get("X-Session"); value != redactedValue {
+		t.Errorf("replayed header %q, want %q", value, redactedValue)
+	}
+	if served != 1 {
+		t.Errorf("the server was called %d times, want 1", served)
+	} else if unused := player.Unused(); len(unused) != 0 {
+		t.Errorf("%d interactions left unused", len(unused))
+	}
+
+	// every interaction is replayed once
+	var unmatched *UnmatchedRequestError
+	if _, _, err = do(player, srv.URL+"/users"); !errors.As(err, &unmatched) {
+		t.Errorf("replaying again returned %v, want UnmatchedRequestError", err)
+	}
+}
+
+func TestCassetteKeepsRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recorder, err := NewCassette(path, CassetteRecord, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		newBody func() io.Reader
+	}{
+		{name: "replayable body", newBody: func() io.Reader { return strings.NewReader(`{"name":"first"}`) }},
+		{name: "stream body", newBody: func() io.Reader { return io.MultiReader(strings.NewReader(`{"name":"first"}`)) }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, srv.URL+"/users", test.newBody())
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, getBody := req.Body, req.GetBody
+			res, err := recorder.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if echoed, _ := io.ReadAll(res.Body); string(echoed) != `{"name":"first"}` {
+				t.Errorf("server received %q", echoed)
+			}
+			if req.Body != body || (req.GetBody == nil) != (getBody == nil) {
+				t.Error("the body of the caller's request was replaced")
+			}
+		})
+	}
+	if err = recorder.Save(); err != nil {
+		t.Fatal(err)
+	}
+	player, err := NewCassette(path, CassetteReplay, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := player.Unused()
+	if len(recorded) != 2 {
+		t.Fatalf("recorded %d interactions, want 2", len(recorded))
+	}
+	for _, interaction := range recorded {
+		if interaction.Request.Body != `{"name":"first"}` {
+			t.Errorf("recorded body %q", interaction.Request.Body)
+		}
+	}
+}
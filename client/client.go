@@ -2,9 +2,11 @@ package client
 
 import (
 	"errors"
+	"github.com/spi-ca/eighty"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
@@ -31,10 +33,13 @@ type Client interface {
 	Head(url string) (resp *http.Response, err error)
 	Post(url string, contentType string, body io.Reader) (resp *http.Response, err error)
 	PostForm(url string, data url.Values) (resp *http.Response, err error)
+	// WithRedirectPolicy returns a Client that shares the transport and follows redirects by the policy.
+	WithRedirectPolicy(policy RedirectPolicy) Client
 }
 
 type wrappedClient struct {
 	http.Client
+	redirectPolicy RedirectPolicy
 }
 
 func (cli *wrappedClient) HttpClient() *http.Client {
@@ -48,7 +53,62 @@ func (cli *wrappedClient) RoundTrip(req *http.Request) (*http.Response, error) {
 	return cli.Client.Transport.RoundTrip(req)
 }
 
+func (cli *wrappedClient) WithRedirectPolicy(policy RedirectPolicy) Client {
+	return &wrappedClient{
+		Client: http.Client{
+			Transport:     cli.Transport,
+			CheckRedirect: policy.CheckRedirect,
+			Jar:           cli.Jar,
+			Timeout:       cli.Timeout,
+		},
+		redirectPolicy: policy,
+	}
+}
+
+func (cli *wrappedClient) Do(req *http.Request) (res *http.Response, err error) {
+	if cli.redirectPolicy == nil {
+		return cli.Client.Do(req)
+	}
+	if req, err = cli.redirectPolicy.prepare(req); err != nil {
+		return
+	}
+	if res, err = cli.Client.Do(req); err == nil {
+		cli.redirectPolicy.complete(res)
+	}
+	return
+}
+
+func (cli *wrappedClient) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return cli.Do(req)
+}
+
+func (cli *wrappedClient) Head(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodHead, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return cli.Do(req)
+}
+
+func (cli *wrappedClient) Post(url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(eighty.ContentTypeHeader, contentType)
+	return cli.Do(req)
+}
+
+func (cli *wrappedClient) PostForm(url string, data url.Values) (*http.Response, error) {
+	return cli.Post(url, eighty.UrlencodeContentType[0], strings.NewReader(data.Encode()))
+}
+
 // NewClient returns a Client interface that has some tunable parameters.
+// The dialer can be customized with dialOptions.
 func NewClient(
 	keepaliveDuration time.Duration,
 	connectTimeout time.Duration,
@@ -57,6 +117,36 @@ func NewClient(
 	maxIdleConnections int,
 	redirectSupport bool,
 	serverName string,
+	dialOptions ...DialOption,
+) Client {
+
+	var redirectChecker func(*http.Request, []*http.Request) error
+	if redirectSupport {
+		redirectChecker = limitedRedirect
+	} else {
+		redirectChecker = disableRedirect
+	}
+
+	return &wrappedClient{
+		Client: http.Client{
+			Transport:     NewRoundTripper(keepaliveDuration, connectTimeout, responseHeaderTimeout, idleConnectionTimeout, maxIdleConnections, serverName, dialOptions...),
+			CheckRedirect: redirectChecker,
+			Jar:           nil,
+		},
+	}
+}
+
+// NewFasthttpClient returns a Client interface that is backed by the fasthttp.Client.
+// It takes the same tunable parameters as NewClient.
+func NewFasthttpClient(
+	keepaliveDuration time.Duration,
+	connectTimeout time.Duration,
+	responseHeaderTimeout time.Duration,
+	idleConnectionTimeout time.Duration,
+	maxIdleConnections int,
+	redirectSupport bool,
+	serverName string,
+	dialOptions ...DialOption,
 ) Client {
 
 	var redirectChecker func(*http.Request, []*http.Request) error
@@ -68,7 +158,7 @@ func NewClient(
 
 	return &wrappedClient{
 		Client: http.Client{
-			Transport:     NewRoundTripper(keepaliveDuration, connectTimeout, responseHeaderTimeout, idleConnectionTimeout, maxIdleConnections, serverName),
+			Transport:     NewFasthttpRoundTripper(keepaliveDuration, connectTimeout, responseHeaderTimeout, idleConnectionTimeout, maxIdleConnections, serverName, dialOptions...),
 			CheckRedirect: redirectChecker,
 			Jar:           nil,
 		},
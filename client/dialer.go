@@ -0,0 +1,361 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// UnixScheme is the URL scheme for http over a unix-domain socket registered by WithUnixSocket.
+	UnixScheme = "http+unix"
+	// DefaultConnWaitTimeout is the default wait for a free connection of WithConnWaitTimeout.
+	DefaultConnWaitTimeout = 30 * time.Second
+)
+
+// Collection of address family preferences for the happy-eyeballs dialing.
+const (
+	// PreferResolved treats the family of the first resolved address as the primary.
+	PreferResolved IPPreference = iota
+	// PreferIPv4 dials IPv4 addresses first and falls back to IPv6.
+	PreferIPv4
+	// PreferIPv6 dials IPv6 addresses first and falls back to IPv4.
+	PreferIPv6
+	// OnlyIPv4 never dials IPv6 addresses.
+	OnlyIPv4
+	// OnlyIPv6 never dials IPv4 addresses.
+	OnlyIPv6
+)
+
+type (
+	// IPPreference is an address family preference for the happy-eyeballs dialing.
+	IPPreference int
+
+	// DialOption customizes the dialer of NewRoundTripper and NewClient.
+	DialOption func(d *dialer)
+
+	// DNSCache is an in-process host name cache for the dialer.
+	DNSCache interface {
+		// LookupHost returns the cached addresses of the host, resolving it on a miss or on expiry.
+		LookupHost(ctx context.Context, host string) (addrs []string, err error)
+		// Forget drops the cached entry of the host.
+		Forget(host string)
+		// Flush drops all cached entries.
+		Flush()
+	}
+
+	dnsCacheEntry struct {
+		addrs   []string
+		err     error
+		expires time.Time
+	}
+	dnsCacheImpl struct {
+		resolver    *net.Resolver
+		ttl         time.Duration
+		negativeTTL time.Duration
+
+		lock    sync.RWMutex
+		entries map[string]dnsCacheEntry
+	}
+
+	dialer struct {
+		net.Dialer
+		unixSockets   map[string]string
+		hostOverrides map[string]string
+		dnsCache      DNSCache
+		preference    IPPreference
+		selfResolve   bool
+		tlsConfig     *tls.Config
+		proxySelector ProxySelector
+		// connWaitTimeout bounds the wait of the fasthttp round tripper for a free connection
+		connWaitTimeout time.Duration
+	}
+
+	dialResult struct {
+		net.Conn
+		error
+		primary bool
+	}
+)
+
+// NewDNSCache returns a DNSCache that keeps resolved addresses for ttl and
+// not-found answers for negativeTTL. If resolver is nil, net.DefaultResolver is used.
+func NewDNSCache(resolver *net.Resolver, ttl, negativeTTL time.Duration) DNSCache {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return &dnsCacheImpl{
+		resolver:    resolver,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		entries:     make(map[string]dnsCacheEntry),
+	}
+}
+
+func (c *dnsCacheImpl) LookupHost(ctx context.Context, host string) ([]string, error) {
+	now := time.Now()
+	c.lock.RLock()
+	entry, ok := c.entries[host]
+	c.lock.RUnlock()
+	if ok && now.Before(entry.expires) {
+		return entry.addrs, entry.err
+	}
+
+	addrs, err := c.resolver.LookupHost(ctx, host)
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil:
+		entry = dnsCacheEntry{addrs: addrs, expires: now.Add(c.ttl)}
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound && c.negativeTTL > 0:
+		entry = dnsCacheEntry{err: err, expires: now.Add(c.negativeTTL)}
+	default:
+		// temporary failures are never cached
+		return nil, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries[host] = entry
+	return entry.addrs, entry.err
+}
+
+func (c *dnsCacheImpl) Forget(host string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.entries, host)
+}
+
+func (c *dnsCacheImpl) Flush() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries = make(map[string]dnsCacheEntry)
+}
+
+// WithUnixSocket dials the unix-domain socket at socketPath for requests to the host.
+// The host can be addressed with either the http or the UnixScheme scheme.
+func WithUnixSocket(host, socketPath string) DialOption {
+	return func(d *dialer) {
+		if d.unixSockets == nil {
+			d.unixSockets = make(map[string]string)
+		}
+		d.unixSockets[host] = socketPath
+	}
+}
+
+// WithHostOverrides dials the overridden address instead of the requested one.
+// Keys are either "host" or "host:port", values are either "addr" or "addr:port".
+// If a value has no port, the requested port is kept.
+func WithHostOverrides(overrides map[string]string) DialOption {
+	return func(d *dialer) {
+		if d.hostOverrides == nil {
+			d.hostOverrides = make(map[string]string)
+		}
+		for from, to := range overrides {
+			d.hostOverrides[from] = to
+		}
+	}
+}
+
+// WithDNSCache resolves host names through the given DNSCache.
+func WithDNSCache(cache DNSCache) DialOption {
+	return func(d *dialer) {
+		d.dnsCache = cache
+		d.selfResolve = true
+	}
+}
+
+// WithHappyEyeballs sets the address family preference and the delay before
+// the fallback family is dialed. A negative fallbackDelay disables racing.
+func WithHappyEyeballs(fallbackDelay time.Duration, preference IPPreference) DialOption {
+	return func(d *dialer) {
+		d.FallbackDelay = fallbackDelay
+		d.preference = preference
+		d.selfResolve = d.selfResolve || preference != PreferResolved
+	}
+}
+
+// WithConnWaitTimeout sets how long the round tripper of NewFasthttpRoundTripper waits for a free connection
+// once the host reached maxIdleConnections, shortened by the request deadline. The default is DefaultConnWaitTimeout.
+// The round tripper of NewRoundTripper waits as long as the request context allows.
+func WithConnWaitTimeout(timeout time.Duration) DialOption {
+	return func(d *dialer) {
+		d.connWaitTimeout = timeout
+	}
+}
+
+func newDialer(connectTimeout, keepaliveDuration time.Duration, options ...DialOption) *dialer {
+	d := &dialer{
+		Dialer: net.Dialer{
+			Timeout:   connectTimeout,
+			KeepAlive: keepaliveDuration,
+		},
+		connWaitTimeout: DefaultConnWaitTimeout,
+	}
+	for _, option := range options {
+		option(d)
+	}
+	return d
+}
+
+// rewriteUnixScheme returns a copy of the request that targets the http scheme
+// when the request uses the UnixScheme.
+func (d *dialer) rewriteUnixScheme(req *http.Request) (*http.Request, error) {
+	if req.URL.Scheme != UnixScheme {
+		return req, nil
+	} else if _, ok := d.unixSockets[req.URL.Hostname()]; !ok {
+		return nil, errors.New("unknown unix socket host: " + req.URL.Hostname())
+	}
+	req = req.Clone(req.Context())
+	req.URL.Scheme = "http"
+	return req, nil
+}
+
+func (d *dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if socketPath, ok := d.unixSockets[host]; ok {
+		return d.Dialer.DialContext(ctx, "unix", socketPath)
+	}
+
+	if override, ok := d.hostOverrides[addr]; ok {
+		host = override
+	} else if override, ok = d.hostOverrides[host]; ok {
+		host = override
+	}
+	if overriddenHost, overriddenPort, splitErr := net.SplitHostPort(host); splitErr == nil {
+		host, port = overriddenHost, overriddenPort
+	}
+
+	if !d.selfResolve || net.ParseIP(host) != nil {
+		return d.Dialer.DialContext(ctx, network, net.JoinHostPort(host, port))
+	}
+
+	var addrs []string
+	if d.dnsCache != nil {
+		addrs, err = d.dnsCache.LookupHost(ctx, host)
+	} else if d.Resolver != nil {
+		addrs, err = d.Resolver.LookupHost(ctx, host)
+	} else {
+		addrs, err = net.DefaultResolver.LookupHost(ctx, host)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	primaries, fallbacks := d.partition(addrs)
+	if len(primaries) == 0 {
+		primaries, fallbacks = fallbacks, nil
+	}
+	if len(primaries) == 0 {
+		return nil, &net.AddrError{Err: "no suitable address found", Addr: host}
+	} else if len(fallbacks) == 0 || d.FallbackDelay < 0 {
+		return d.dialSerial(ctx, network, port, append(primaries, fallbacks...))
+	}
+	return d.dialParallel(ctx, network, port, primaries, fallbacks)
+}
+
+// partition splits addresses into the primary and the fallback family by the preference.
+func (d *dialer) partition(addrs []string) (primaries, fallbacks []string) {
+	var v4, v6 []string
+	for _, addr := range addrs {
+		if ip := net.ParseIP(addr); ip == nil {
+			continue
+		} else if ip.To4() != nil {
+			v4 = append(v4, addr)
+		} else {
+			v6 = append(v6, addr)
+		}
+	}
+	switch d.preference {
+	case OnlyIPv4:
+		return v4, nil
+	case OnlyIPv6:
+		return v6, nil
+	case PreferIPv4:
+		return v4, v6
+	case PreferIPv6:
+		return v6, v4
+	}
+	if len(v4) > 0 && len(v6) > 0 && net.ParseIP(v6[0]).Equal(net.ParseIP(addrs[0])) {
+		return v6, v4
+	} else if len(v4) > 0 {
+		return v4, v6
+	}
+	return v6, nil
+}
+
+func (d *dialer) dialSerial(ctx context.Context, network, port string, addrs []string) (conn net.Conn, err error) {
+	for _, addr := range addrs {
+		if conn, err = d.Dialer.DialContext(ctx, network, net.JoinHostPort(addr, port)); err == nil {
+			return
+		} else if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	return
+}
+
+// dialParallel races the primary family against the fallback family that starts after FallbackDelay.
+func (d *dialer) dialParallel(ctx context.Context, network, port string, primaries, fallbacks []string) (net.Conn, error) {
+	returned := make(chan struct{})
+	defer close(returned)
+
+	results := make(chan dialResult)
+	racer := func(ctx context.Context, addrs []string, primary bool) {
+		conn, err := d.dialSerial(ctx, network, port, addrs)
+		select {
+		case results <- dialResult{Conn: conn, error: err, primary: primary}:
+		case <-returned:
+			if conn != nil {
+				_ = conn.Close()
+			}
+		}
+	}
+
+	primaryCtx, primaryCancel := context.WithCancel(ctx)
+	defer primaryCancel()
+	go racer(primaryCtx, primaries, true)
+
+	fallbackDelay := d.FallbackDelay
+	if fallbackDelay == 0 {
+		fallbackDelay = 300 * time.Millisecond
+	}
+	fallbackTimer := time.NewTimer(fallbackDelay)
+	defer fallbackTimer.Stop()
+
+	var (
+		primaryErr, fallbackErr error
+		fallbackStarted         bool
+	)
+	for {
+		select {
+		case <-fallbackTimer.C:
+			fallbackCtx, fallbackCancel := context.WithCancel(ctx)
+			defer fallbackCancel()
+			go racer(fallbackCtx, fallbacks, false)
+			fallbackStarted = true
+		case res := <-results:
+			if res.error == nil {
+				return res.Conn, nil
+			}
+			if res.primary {
+				primaryErr = res.error
+			} else {
+				fallbackErr = res.error
+			}
+			if primaryErr != nil && fallbackErr != nil {
+				return nil, primaryErr
+			}
+			if res.primary && !fallbackStarted && fallbackTimer.Stop() {
+				// the primary family failed, start the fallback immediately
+				fallbackTimer.Reset(0)
+			}
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/spi-ca/eighty"
+	"github.com/spi-ca/misc/backoff"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	rangeHeader        = "Range"
+	ifRangeHeader      = "If-Range"
+	acceptRangesHeader = "Accept-Ranges"
+	contentRangeHeader = "Content-Range"
+)
+
+var (
+	// ErrChecksumMismatch is returned when the downloaded content does not match the expected checksum.
+	ErrChecksumMismatch = errors.New("downloaded content checksum mismatch")
+	// ErrResourceChanged is returned when the resource changed while its chunks were downloaded.
+	ErrResourceChanged = errors.New("resource changed during download")
+)
+
+type (
+	// Downloader is a resumable downloader on top of the Client.
+	Downloader interface {
+		// Download writes the resource at url into dst and returns its size.
+		// If newHash is not nil, the content is verified against expectedSum.
+		// progress, if not nil, receives the written bytes and the total bytes, -1 if unknown.
+		Download(
+			ctx context.Context, url string, dst io.WriterAt,
+			newHash func() hash.Hash, expectedSum []byte,
+			progress func(written, total int64),
+		) (size int64, err error)
+		// DownloadFile works like Download, writing into the file at path.
+		DownloadFile(
+			ctx context.Context, url string, path string,
+			newHash func() hash.Hash, expectedSum []byte,
+			progress func(written, total int64),
+		) (size int64, err error)
+	}
+
+	downloaderImpl struct {
+		cli          Client
+		maxRetries   int
+		retryBackoff backoff.Algorithm
+		chunks       int
+		minChunkSize int64
+	}
+
+	downloadJob struct {
+		url       string
+		validator string
+		total     int64
+		written   int64
+		progress  func(written, total int64)
+	}
+
+	// segmentWriter writes a sequential stream into the io.WriterAt starting at offset.
+	segmentWriter struct {
+		job    *downloadJob
+		dst    io.WriterAt
+		offset int64
+		hash   hash.Hash
+	}
+)
+
+func (w *segmentWriter) Write(p []byte) (n int, err error) {
+	n, err = w.dst.WriteAt(p, w.offset)
+	w.offset += int64(n)
+	if w.hash != nil {
+		_, _ = w.hash.Write(p[:n])
+	}
+	w.job.advance(int64(n))
+	return
+}
+
+func (job *downloadJob) advance(n int64) {
+	written := atomic.AddInt64(&job.written, n)
+	if job.progress != nil && n != 0 {
+		job.progress(written, job.total)
+	}
+}
+
+// NewDownloader returns a Downloader.
+// A failed transfer is resumed up to maxRetries times, waiting retryBackoff between attempts.
+// If the server supports ranges and the size is known, the download is split into
+// up to chunks parallel parts of at least minChunkSize bytes.
+func NewDownloader(cli Client, maxRetries int, retryBackoff backoff.Algorithm, chunks int, minChunkSize int64) Downloader {
+	if retryBackoff == nil {
+		retryBackoff = backoff.BinaryExponential(100 * time.Millisecond)
+	}
+	if chunks < 1 {
+		chunks = 1
+	}
+	return &downloaderImpl{
+		cli:          cli,
+		maxRetries:   maxRetries,
+		retryBackoff: retryBackoff,
+		chunks:       chunks,
+		minChunkSize: minChunkSize,
+	}
+}
+
+func (d *downloaderImpl) DownloadFile(
+	ctx context.Context, url string, path string,
+	newHash func() hash.Hash, expectedSum []byte,
+	progress func(written, total int64),
+) (size int64, err error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	if size, err = d.Download(ctx, url, f, newHash, expectedSum, progress); err == nil {
+		err = f.Truncate(size)
+	}
+	return
+}
+
+func (d *downloaderImpl) Download(
+	ctx context.Context, url string, dst io.WriterAt,
+	newHash func() hash.Hash, expectedSum []byte,
+	progress func(written, total int64),
+) (size int64, err error) {
+	job := &downloadJob{url: url, total: -1, progress: progress}
+	acceptRanges := d.probe(ctx, job)
+
+	reader, readable := dst.(io.ReaderAt)
+	verifyLater := newHash != nil && readable
+	chunked := acceptRanges && job.total > 0 && d.chunks > 1 && (newHash == nil || verifyLater)
+
+	var streamHash hash.Hash
+	if newHash != nil && !verifyLater {
+		streamHash = newHash()
+	}
+	if chunked {
+		size, err = d.downloadChunks(ctx, job, dst)
+	} else {
+		size, err = d.downloadSequential(ctx, job, dst, streamHash)
+	}
+	if err != nil || newHash == nil {
+		return
+	}
+
+	if verifyLater {
+		streamHash = newHash()
+		if _, err = io.Copy(streamHash, io.NewSectionReader(reader, 0, size)); err != nil {
+			return
+		}
+	}
+	if !bytes.Equal(streamHash.Sum(nil), expectedSum) {
+		err = ErrChecksumMismatch
+	}
+	return
+}
+
+// probe fetches the size and the validator of the resource and reports whether it supports ranges.
+func (d *downloaderImpl) probe(ctx context.Context, job *downloadJob) (acceptRanges bool) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, job.url, nil)
+	if err != nil {
+		return
+	}
+	res, err := d.cli.Do(req)
+	if err != nil {
+		return
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return
+	}
+	job.total = res.ContentLength
+	job.validator = d.validator(res)
+	return strings.Contains(res.Header.Get(acceptRangesHeader), "bytes") && len(job.validator) > 0
+}
+
+// validator returns the If-Range value of the response, a strong ETag or the Last-Modified date.
+func (d *downloaderImpl) validator(res *http.Response) string {
+	if etag := res.Header.Get(eighty.EtagHeader); len(etag) > 0 && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return res.Header.Get(eighty.LastModifiedHeader)
+}
+
+func (d *downloaderImpl) wait(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(d.retryBackoff(uint(attempt)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *downloaderImpl) downloadSequential(ctx context.Context, job *downloadJob, dst io.WriterAt, streamHash hash.Hash) (int64, error) {
+	w := &segmentWriter{job: job, dst: dst, hash: streamHash}
+	for attempt := 0; ; attempt++ {
+		err := d.fetch(ctx, job, w, -1, true)
+		if err == nil {
+			return w.offset, nil
+		}
+		var resErr *ResponseError
+		if attempt >= d.maxRetries || ctx.Err() != nil || errors.As(err, &resErr) && resErr.StatusCode < 500 {
+			return w.offset, err
+		} else if err = d.wait(ctx, attempt); err != nil {
+			return w.offset, err
+		}
+	}
+}
+
+func (d *downloaderImpl) downloadChunks(parent context.Context, job *downloadJob, dst io.WriterAt) (int64, error) {
+	chunkSize := (job.total + int64(d.chunks) - 1) / int64(d.chunks)
+	if chunkSize < d.minChunkSize {
+		chunkSize = d.minChunkSize
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	var (
+		waiter   sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for start := int64(0); start < job.total; start += chunkSize {
+		end := start + chunkSize - 1
+		if end >= job.total {
+			end = job.total - 1
+		}
+		waiter.Add(1)
+		go func(start, end int64) {
+			defer waiter.Done()
+			w := &segmentWriter{job: job, dst: dst, offset: start}
+			for attempt := 0; ; attempt++ {
+				err := d.fetch(ctx, job, w, end, false)
+				if err == nil {
+					return
+				}
+				var resErr *ResponseError
+				if attempt >= d.maxRetries || ctx.Err() != nil || err == ErrResourceChanged || errors.As(err, &resErr) && resErr.StatusCode < 500 {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				} else if err = d.wait(ctx, attempt); err != nil {
+					return
+				}
+			}
+		}(start, end)
+	}
+	waiter.Wait()
+	if firstErr == nil {
+		firstErr = parent.Err()
+	}
+	return job.total, firstErr
+}
+
+// fetch transfers the range from w.offset to end (inclusive, -1 for the rest) into w.
+// If restartable, a full response to a ranged request restarts the transfer from zero.
+func (d *downloaderImpl) fetch(ctx context.Context, job *downloadJob, w *segmentWriter, end int64, restartable bool) error {
+	if end >= 0 && w.offset > end {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, job.url, nil)
+	if err != nil {
+		return err
+	}
+	ranged := w.offset > 0 || end >= 0
+	if ranged {
+		byteRange := "bytes=" + strconv.FormatInt(w.offset, 10) + "-"
+		if end >= 0 {
+			byteRange += strconv.FormatInt(end, 10)
+		}
+		req.Header.Set(rangeHeader, byteRange)
+		if len(job.validator) > 0 {
+			req.Header.Set(ifRangeHeader, job.validator)
+		}
+	}
+
+	res, err := d.cli.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusPartialContent && ranged:
+		if !strings.HasPrefix(res.Header.Get(contentRangeHeader), "bytes "+strconv.FormatInt(w.offset, 10)+"-") {
+			return ErrResourceChanged
+		}
+	case res.StatusCode == http.StatusOK:
+		if ranged && !restartable {
+			return ErrResourceChanged
+		} else if ranged {
+			// the resource changed or ranges are not supported, start over
+			job.advance(-w.offset)
+			w.offset = 0
+			if w.hash != nil {
+				w.hash.Reset()
+			}
+		}
+		// a full response describes the current resource, later resumes must validate against it
+		job.validator = d.validator(res)
+		job.total = res.ContentLength
+	default:
+		body, _ := io.ReadAll(io.LimitReader(res.Body, DefaultJSONResponseLimit))
+		return newResponseError(res, body)
+	}
+
+	_, err = io.Copy(w, res.Body)
+	return err
+}
@@ -0,0 +1,240 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	hedgeLatencySamples    = 128
+	hedgeMinLatencySamples = 16
+	hedgeDrainLimit        = 64 << 10
+)
+
+type (
+	hedgeAttempt struct {
+		idx       int
+		startedAt time.Time
+		res       *http.Response
+		err       error
+	}
+
+	cancelOnCloseBody struct {
+		io.ReadCloser
+		cancel context.CancelFunc
+	}
+
+	hedgingTransport struct {
+		next           http.RoundTripper
+		delay          time.Duration
+		percentile     float64
+		maxHedges      int
+		budgetRatio    float64
+		alternateHosts []string
+
+		requests int64
+		hedges   int64
+
+		latencyLock sync.Mutex
+		latencies   [hedgeLatencySamples]time.Duration
+		latencyIdx  int
+		latencyLen  int
+	}
+)
+
+func (b *cancelOnCloseBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// NewHedgingTransport returns a http.RoundTripper that re-issues idempotent requests
+// when the response does not arrive in time.
+// A hedge is sent after delay, or after the given percentile (0 < percentile < 1) of
+// the observed latencies once enough samples are collected.
+// Up to maxHedges hedges are sent per request, rotating over alternateHosts if given.
+// budgetRatio caps the hedges at that fraction of all requests.
+// The first successful response wins, the others are cancelled and drained.
+func NewHedgingTransport(
+	next http.RoundTripper,
+	delay time.Duration,
+	percentile float64,
+	maxHedges int,
+	budgetRatio float64,
+	alternateHosts ...string,
+) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &hedgingTransport{
+		next:           next,
+		delay:          delay,
+		percentile:     percentile,
+		maxHedges:      maxHedges,
+		budgetRatio:    budgetRatio,
+		alternateHosts: alternateHosts,
+	}
+}
+
+func (t *hedgingTransport) hedgeable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func (t *hedgingTransport) observe(latency time.Duration) {
+	t.latencyLock.Lock()
+	defer t.latencyLock.Unlock()
+	t.latencies[t.latencyIdx] = latency
+	t.latencyIdx = (t.latencyIdx + 1) % hedgeLatencySamples
+	if t.latencyLen < hedgeLatencySamples {
+		t.latencyLen++
+	}
+}
+
+func (t *hedgingTransport) hedgeDelay() time.Duration {
+	if t.percentile <= 0 || t.percentile >= 1 {
+		return t.delay
+	}
+	t.latencyLock.Lock()
+	if t.latencyLen < hedgeMinLatencySamples {
+		t.latencyLock.Unlock()
+		return t.delay
+	}
+	samples := append([]time.Duration(nil), t.latencies[:t.latencyLen]...)
+	t.latencyLock.Unlock()
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return samples[int(float64(len(samples)-1)*t.percentile)]
+}
+
+func (t *hedgingTransport) acquireBudget() bool {
+	allowed := int64(t.budgetRatio * float64(atomic.LoadInt64(&t.requests)))
+	for {
+		hedges := atomic.LoadInt64(&t.hedges)
+		if hedges >= allowed {
+			return false
+		} else if atomic.CompareAndSwapInt64(&t.hedges, hedges, hedges+1) {
+			return true
+		}
+	}
+}
+
+func (t *hedgingTransport) launch(req *http.Request, idx int, results chan<- hedgeAttempt) context.CancelFunc {
+	ctx, cancel := context.WithCancel(req.Context())
+	attempt, startedAt := req.Clone(ctx), time.Now()
+	if idx > 0 {
+		if len(t.alternateHosts) > 0 {
+			attempt.URL.Host = t.alternateHosts[(idx-1)%len(t.alternateHosts)]
+			attempt.Host = ""
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				results <- hedgeAttempt{idx: idx, startedAt: startedAt, err: err}
+				return cancel
+			}
+			attempt.Body = body
+		}
+	}
+	go func() {
+		res, err := t.next.RoundTrip(attempt)
+		results <- hedgeAttempt{idx: idx, startedAt: startedAt, res: res, err: err}
+	}()
+	return cancel
+}
+
+// drain drains and closes the response of a losing attempt, so that its connection can be reused.
+func drain(res *http.Response) {
+	if res != nil {
+		_, _ = io.CopyN(io.Discard, res.Body, hedgeDrainLimit)
+		_ = res.Body.Close()
+	}
+}
+
+// discard drains and closes the responses of the remaining attempts.
+func (t *hedgingTransport) discard(results <-chan hedgeAttempt, remaining int) {
+	for ; remaining > 0; remaining-- {
+		drain((<-results).res)
+	}
+}
+
+func (t *hedgingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt64(&t.requests, 1)
+	if t.maxHedges <= 0 || !t.hedgeable(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	var (
+		results  = make(chan hedgeAttempt, t.maxHedges+1)
+		cancels  = []context.CancelFunc{t.launch(req, 0, results)}
+		inflight = 1
+		failed   *hedgeAttempt
+	)
+	// cancelExcept cancels all attempts but the given one.
+	cancelExcept := func(idx int) {
+		for i, cancel := range cancels {
+			if i != idx {
+				cancel()
+			}
+		}
+	}
+	// hedge starts the next attempt if it is allowed.
+	hedge := func() {
+		if len(cancels) <= t.maxHedges && t.acquireBudget() {
+			cancels = append(cancels, t.launch(req, len(cancels), results))
+			inflight++
+		}
+	}
+
+	delay := t.hedgeDelay()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	for inflight > 0 {
+		select {
+		case <-timer.C:
+			hedge()
+			// stop hedging once the limit is reached
+			if len(cancels) <= t.maxHedges {
+				timer.Reset(delay)
+			}
+		case attempt := <-results:
+			inflight--
+			if attempt.err == nil && attempt.res.StatusCode < http.StatusInternalServerError {
+				t.observe(time.Since(attempt.startedAt))
+				// drain the kept failure before its cancellation, so that its connection can be reused
+				if failed != nil {
+					drain(failed.res)
+				}
+				cancelExcept(attempt.idx)
+				go t.discard(results, inflight)
+				attempt.res.Body = &cancelOnCloseBody{ReadCloser: attempt.res.Body, cancel: cancels[attempt.idx]}
+				return attempt.res, nil
+			}
+			if failed == nil {
+				failed = &attempt
+			} else {
+				drain(attempt.res)
+			}
+			// the attempt failed, do not wait for the delay
+			hedge()
+		}
+	}
+
+	// every attempt failed, answer with the first failure
+	cancelExcept(failed.idx)
+	if failed.res == nil {
+		cancels[failed.idx]()
+	} else {
+		failed.res.Body = &cancelOnCloseBody{ReadCloser: failed.res.Body, cancel: cancels[failed.idx]}
+	}
+	return failed.res, failed.err
+}
@@ -0,0 +1,158 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// hedgeStep is the scripted outcome of an attempt: it answers with the status after the wait,
+// or fails once its request is cancelled if status is zero.
+type hedgeStep struct {
+	wait   time.Duration
+	status int
+}
+
+// scriptedTransport answers the attempts in launch order by the steps and records the attempted hosts.
+type scriptedTransport struct {
+	lock      sync.Mutex
+	steps     []hedgeStep
+	hosts     []string
+	cancelled int
+}
+
+func (s *scriptedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.lock.Lock()
+	idx := len(s.hosts)
+	s.hosts = append(s.hosts, req.URL.Host)
+	step := s.steps[idx%len(s.steps)]
+	s.lock.Unlock()
+
+	var expired <-chan time.Time
+	if step.status > 0 {
+		expired = time.After(step.wait)
+	}
+	select {
+	case <-expired:
+		body := req.URL.Host + " " + http.StatusText(step.status)
+		return &http.Response{StatusCode: step.status, Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+	case <-req.Context().Done():
+		s.lock.Lock()
+		s.cancelled++
+		s.lock.Unlock()
+		return nil, req.Context().Err()
+	}
+}
+
+func (s *scriptedTransport) attempts() ([]string, int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string(nil), s.hosts...), s.cancelled
+}
+
+func TestHedgingTransport(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	tests := []struct {
+		name           string
+		method         string
+		steps          []hedgeStep
+		maxHedges      int
+		budgetRatio    float64
+		alternateHosts []string
+		wantStatus     int
+		wantBody       string
+		wantHosts      []string
+	}{
+		{name: "fast primary", method: http.MethodGet, steps: []hedgeStep{{status: http.StatusOK}},
+			maxHedges: 2, budgetRatio: 1, wantStatus: http.StatusOK, wantBody: "origin.example.com OK",
+			wantHosts: []string{"origin.example.com"}},
+		{name: "hedge wins", method: http.MethodGet, steps: []hedgeStep{{}, {status: http.StatusOK}},
+			maxHedges: 2, budgetRatio: 1, alternateHosts: []string{"replica.example.com"},
+			wantStatus: http.StatusOK, wantBody: "replica.example.com OK",
+			wantHosts: []string{"origin.example.com", "replica.example.com"}},
+		{name: "failure hedges at once", method: http.MethodGet,
+			steps:     []hedgeStep{{status: http.StatusServiceUnavailable}, {wait: delay / 4, status: http.StatusOK}},
+			maxHedges: 1, budgetRatio: 1, wantStatus: http.StatusOK, wantBody: "origin.example.com OK",
+			wantHosts: []string{"origin.example.com", "origin.example.com"}},
+		{name: "all fail", method: http.MethodGet,
+			steps:     []hedgeStep{{status: http.StatusServiceUnavailable}, {status: http.StatusBadGateway}},
+			maxHedges: 1, budgetRatio: 1, wantStatus: http.StatusServiceUnavailable,
+			wantHosts: []string{"origin.example.com", "origin.example.com"}},
+		{name: "hedge limit", method: http.MethodGet,
+			steps:     []hedgeStep{{wait: 8 * delay, status: http.StatusOK}},
+			maxHedges: 2, budgetRatio: 2, wantStatus: http.StatusOK,
+			wantHosts: []string{"origin.example.com", "origin.example.com", "origin.example.com"}},
+		{name: "no budget", method: http.MethodGet, steps: []hedgeStep{{wait: 3 * delay, status: http.StatusOK}},
+			maxHedges: 2, budgetRatio: 0, wantStatus: http.StatusOK, wantHosts: []string{"origin.example.com"}},
+		{name: "not idempotent", method: http.MethodPost, steps: []hedgeStep{{wait: 3 * delay, status: http.StatusOK}},
+			maxHedges: 2, budgetRatio: 1, wantStatus: http.StatusOK, wantHosts: []string{"origin.example.com"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			next := &scriptedTransport{steps: test.steps}
+			transport := NewHedgingTransport(next, delay, 0, test.maxHedges, test.budgetRatio, test.alternateHosts...)
+			req, err := http.NewRequest(test.method, "http://origin.example.com/items", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(res.Body)
+			_ = res.Body.Close()
+			if res.StatusCode != test.wantStatus {
+				t.Errorf("status %d, want %d", res.StatusCode, test.wantStatus)
+			} else if len(test.wantBody) > 0 && string(body) != test.wantBody {
+				t.Errorf("body %q, want %q", body, test.wantBody)
+			}
+			if hosts, _ := next.attempts(); strings.Join(hosts, ",") != strings.Join(test.wantHosts, ",") {
+				t.Errorf("attempted %v, want %v", hosts, test.wantHosts)
+			}
+		})
+	}
+}
+
+func TestHedgingTransportCancelsLosers(t *testing.T) {
+	next := &scriptedTransport{steps: []hedgeStep{{}, {status: http.StatusOK}}}
+	transport := NewHedgingTransport(next, 10*time.Millisecond, 0, 1, 1)
+	req, err := http.NewRequest(http.MethodGet, "http://origin.example.com/items", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		if _, cancelled := next.attempts(); cancelled == 1 {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatal("the losing attempt was not cancelled")
+		}
+	}
+
+	// the winner stays readable until its body is closed
+	transport = NewHedgingTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok")), Request: req}, nil
+	}), time.Second, 0, 1, 1)
+	if res, err = transport.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	} else if ctxErr := res.Request.Context().Err(); ctxErr != nil {
+		t.Errorf("the winner is cancelled before its body is closed: %v", ctxErr)
+	}
+	_ = res.Body.Close()
+	if ctxErr := res.Request.Context().Err(); !errors.Is(ctxErr, context.Canceled) {
+		t.Errorf("the winner context is %v after closing the body", ctxErr)
+	}
+}
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/spi-ca/eighty"
+	"github.com/spi-ca/misc"
+	"io"
+	"net/http"
+)
+
+const (
+	// DefaultJSONResponseLimit is the maximum response body size accepted by GetJSON and PostJSON.
+	DefaultJSONResponseLimit int64 = 10 << 20
+)
+
+var (
+	// ErrResponseTooLarge is returned when the response body exceeds the given limit.
+	ErrResponseTooLarge = errors.New("response body too large")
+
+	// Is the interface compatible with the actual dto
+	_ error = (*ResponseError)(nil)
+)
+
+// ResponseError is returned when the upstream answers with a non-2xx status.
+// It unwraps to the nearest eighty.HandledError, so proxying handlers can re-panic it.
+type ResponseError struct {
+	// StatusCode is the upstream http status code.
+	StatusCode int
+	// Status is the upstream http status line.
+	Status string
+	// Body is the upstream problem body, cut at the response limit.
+	Body []byte
+	// Handled is the nearest eighty.HandledError of the upstream status code.
+	Handled eighty.HandledError
+}
+
+// Error implements the built-in interface type error.
+func (e *ResponseError) Error() string {
+	return "unexpected upstream status: " + e.Status
+}
+
+// Unwrap returns the nearest eighty.HandledError.
+func (e *ResponseError) Unwrap() error {
+	return e.Handled
+}
+
+// DecodeProblem decodes the upstream problem body into v.
+func (e *ResponseError) DecodeProblem(v any) error {
+	return misc.JSONCodec.Unmarshal(e.Body, v)
+}
+
+func newResponseError(res *http.Response, body []byte) *ResponseError {
+	return &ResponseError{
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+		Body:       body,
+		Handled:    nearestHandledError(res.StatusCode),
+	}
+}
+
+// nearestHandledError returns the HandledError that matches the status code,
+// or the generic one of the same error class. The other statuses, such as an unfollowed redirect, are kept as is.
+func nearestHandledError(statusCode int) eighty.HandledError {
+	handled, ok := eighty.HandledErrorCodeOf(statusCode)
+	switch {
+	case ok:
+		return handled
+	case statusCode >= 400 && statusCode < 500:
+		return eighty.HandledErrorBadRequest
+	case statusCode >= 500 && statusCode < 600:
+		return eighty.HandledErrorInternalServerError
+	default:
+		return eighty.HandledError(statusCode)
+	}
+}
+
+// GetJSON sends a GET request and decodes the JSON response body into T.
+func GetJSON[T any](ctx context.Context, cli Client, url string) (res T, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return
+	}
+	return DoJSON[T](cli, req, DefaultJSONResponseLimit)
+}
+
+// PostJSON sends a POST request with the JSON encoded body and decodes the JSON response body into Resp.
+func PostJSON[Req, Resp any](ctx context.Context, cli Client, url string, body Req) (res Resp, err error) {
+	stream := misc.JSONCodec.BorrowStream(nil)
+	defer misc.JSONCodec.ReturnStream(stream)
+	if stream.WriteVal(body); stream.Error != nil {
+		err = stream.Error
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(append([]byte(nil), stream.Buffer()...)))
+	if err != nil {
+		return
+	}
+	req.Header.Set(eighty.ContentTypeHeader, eighty.JsonContentUTF8Type[0])
+	return DoJSON[Resp](cli, req, DefaultJSONResponseLimit)
+}
+
+// DoJSON sends the request and decodes the JSON response body into T.
+// A response body larger than maxResponseSize fails with ErrResponseTooLarge,
+// and a non-2xx response fails with the ResponseError.
+func DoJSON[T any](cli Client, req *http.Request, maxResponseSize int64) (res T, err error) {
+	if len(req.Header.Get(eighty.AcceptHeader)) == 0 {
+		req.Header.Set(eighty.AcceptHeader, eighty.JsonContentType[0])
+	}
+	resp, err := cli.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		return
+	}
+	tooLarge := int64(len(body)) > maxResponseSize
+	if tooLarge {
+		body = body[:maxResponseSize]
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err = newResponseError(resp, body)
+		return
+	} else if tooLarge {
+		err = ErrResponseTooLarge
+		return
+	} else if len(body) == 0 {
+		return
+	}
+
+	err = misc.JSONCodec.Unmarshal(body, &res)
+	return
+}
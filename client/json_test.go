@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/spi-ca/eighty"
+)
+
+func TestNearestHandledError(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		want       eighty.HandledError
+	}{
+		{statusCode: http.StatusNotFound, want: eighty.HandledErrorNotFound},
+		{statusCode: http.StatusConflict, want: eighty.HandledErrorBadRequest},
+		{statusCode: http.StatusServiceUnavailable, want: eighty.HandledErrorServiceUnavailable},
+		{statusCode: http.StatusHTTPVersionNotSupported, want: eighty.HandledErrorInternalServerError},
+		{statusCode: http.StatusFound, want: eighty.HandledError(http.StatusFound)},
+		{statusCode: http.StatusNotModified, want: eighty.HandledError(http.StatusNotModified)},
+		{statusCode: http.StatusContinue, want: eighty.HandledError(http.StatusContinue)},
+	}
+	for _, test := range tests {
+		t.Run(strconv.Itoa(test.statusCode), func(t *testing.T) {
+			if handled := nearestHandledError(test.statusCode); handled != test.want {
+				t.Errorf("got %d, want %d", handled, test.want)
+			}
+		})
+	}
+}
+
+func TestDoJSON(t *testing.T) {
+	type item struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if accept := r.Header.Get(eighty.AcceptHeader); accept != eighty.JsonContentType[0] {
+			t.Errorf("Accept %q, want %q", accept, eighty.JsonContentType[0])
+		}
+		switch r.URL.Path {
+		case "/item":
+			_, _ = io.WriteString(w, `{"id":1,"name":"first"}`)
+		case "/large":
+			_, _ = io.WriteString(w, `{"id":1,"name":"a name longer than the limit"}`)
+		case "/cached":
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `{"code":404}`)
+		}
+	}))
+	defer srv.Close()
+	cli := NewClient(0, time.Second, time.Second, time.Second, 1, false, "")
+
+	if got, err := GetJSON[item](context.Background(), cli, srv.URL+"/item"); err != nil {
+		t.Fatal(err)
+	} else if got != (item{ID: 1, Name: "first"}) {
+		t.Errorf("decoded %+v", got)
+	}
+
+	var resErr *ResponseError
+	if _, err := GetJSON[item](context.Background(), cli, srv.URL+"/missing"); !errors.As(err, &resErr) || !errors.Is(err, eighty.HandledErrorNotFound) {
+		t.Errorf("got %v, want a 404 ResponseError", err)
+	} else if string(resErr.Body) != `{"code":404}` {
+		t.Errorf("problem body %q", resErr.Body)
+	}
+	if _, err := GetJSON[item](context.Background(), cli, srv.URL+"/cached"); !errors.As(err, &resErr) || resErr.Handled.StatusCode() != http.StatusNotModified {
+		t.Errorf("got %v, want the 304 ResponseError", err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/large", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = DoJSON[item](cli, req, 16); err != ErrResponseTooLarge {
+		t.Errorf("got %v, want %v", err, ErrResponseTooLarge)
+	}
+}
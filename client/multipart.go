@@ -0,0 +1,231 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/spi-ca/eighty"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type (
+	// MultipartBuilder is a streaming multipart/form-data request body builder.
+	// File parts are streamed from their readers through a pipe, so whole files are never buffered.
+	MultipartBuilder interface {
+		// AddField adds a form field part.
+		AddField(name, value string) MultipartBuilder
+		// AddFile adds a file part streamed from content. A negative size means unknown.
+		AddFile(fieldName, fileName, contentType string, size int64, content io.Reader) MultipartBuilder
+		// AddPart adds a part with custom headers streamed from content. A negative size means unknown.
+		AddPart(header textproto.MIMEHeader, size int64, content io.Reader) MultipartBuilder
+		// OnProgress sets a callback that receives the written bytes and the total bytes, -1 if unknown.
+		OnProgress(progress func(written, total int64)) MultipartBuilder
+		// ContentType returns the Content-Type header value with the boundary.
+		ContentType() string
+		// ContentLength returns the length of the whole body, or -1 if a part size is unknown.
+		ContentLength() int64
+		// Build returns the body reader. It can be called only once.
+		// The parts are written once the body is read, closing the body unread closes their readers.
+		Build() io.ReadCloser
+		// NewRequest returns a http.Request with the body, the Content-Type and the Content-Length.
+		// The body fails with the error of ctx once it is done.
+		NewRequest(ctx context.Context, method, url string) (*http.Request, error)
+	}
+
+	multipartPart struct {
+		header  textproto.MIMEHeader
+		size    int64
+		content io.Reader
+	}
+
+	multipartBuilderImpl struct {
+		boundary string
+		parts    []multipartPart
+		progress func(written, total int64)
+		built    bool
+	}
+
+	// multipartBody starts writing the parts into the pipe on the first read.
+	multipartBody struct {
+		*io.PipeReader
+		start sync.Once
+		write func()
+		abort func()
+	}
+
+	countingWriter struct {
+		w        io.Writer
+		written  int64
+		total    int64
+		progress func(written, total int64)
+	}
+)
+
+func (cw *countingWriter) Write(p []byte) (n int, err error) {
+	if cw.w != nil {
+		n, err = cw.w.Write(p)
+	} else {
+		n = len(p)
+	}
+	cw.written += int64(n)
+	if cw.progress != nil && n > 0 {
+		cw.progress(cw.written, cw.total)
+	}
+	return
+}
+
+func (body *multipartBody) Read(p []byte) (int, error) {
+	body.start.Do(body.write)
+	return body.PipeReader.Read(p)
+}
+
+func (body *multipartBody) Close() error {
+	body.start.Do(body.abort)
+	return body.PipeReader.Close()
+}
+
+// NewMultipartBuilder returns an empty MultipartBuilder with a random boundary.
+func NewMultipartBuilder() MultipartBuilder {
+	return &multipartBuilderImpl{
+		boundary: multipart.NewWriter(nil).Boundary(),
+	}
+}
+
+func (b *multipartBuilderImpl) AddField(name, value string) MultipartBuilder {
+	return b.addFormPart(map[string]string{"name": name}, "", int64(len(value)), strings.NewReader(value))
+}
+
+func (b *multipartBuilderImpl) AddFile(fieldName, fileName, contentType string, size int64, content io.Reader) MultipartBuilder {
+	if len(contentType) == 0 {
+		contentType = "application/octet-stream"
+	}
+	return b.addFormPart(map[string]string{"name": fieldName, "filename": fileName}, contentType, size, content)
+}
+
+// addFormPart adds a form-data part, whose Content-Disposition params are quoted, or encoded if not ASCII.
+func (b *multipartBuilderImpl) addFormPart(params map[string]string, contentType string, size int64, content io.Reader) MultipartBuilder {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", mime.FormatMediaType("form-data", params))
+	if len(contentType) > 0 {
+		header.Set(eighty.ContentTypeHeader, contentType)
+	}
+	return b.AddPart(header, size, content)
+}
+
+func (b *multipartBuilderImpl) AddPart(header textproto.MIMEHeader, size int64, content io.Reader) MultipartBuilder {
+	b.parts = append(b.parts, multipartPart{header: header, size: size, content: content})
+	return b
+}
+
+func (b *multipartBuilderImpl) OnProgress(progress func(written, total int64)) MultipartBuilder {
+	b.progress = progress
+	return b
+}
+
+func (b *multipartBuilderImpl) ContentType() string {
+	return "multipart/form-data; boundary=" + b.boundary
+}
+
+func (b *multipartBuilderImpl) ContentLength() int64 {
+	counter := &countingWriter{}
+	writer := multipart.NewWriter(counter)
+	_ = writer.SetBoundary(b.boundary)
+	var contentSize int64
+	for _, part := range b.parts {
+		if part.size < 0 {
+			return -1
+		}
+		_, _ = writer.CreatePart(part.header)
+		contentSize += part.size
+	}
+	_ = writer.Close()
+	return counter.written + contentSize
+}
+
+func (b *multipartBuilderImpl) Build() io.ReadCloser {
+	return b.build(context.Background(), b.ContentLength())
+}
+
+// build returns the body that writes the parts of total bytes, failing with the error of ctx once it is done.
+func (b *multipartBuilderImpl) build(ctx context.Context, total int64) io.ReadCloser {
+	pipeReader, pipeWriter := io.Pipe()
+	if b.built {
+		_ = pipeWriter.CloseWithError(errors.New("multipart body was already built"))
+		return pipeReader
+	}
+	b.built = true
+
+	return &multipartBody{
+		PipeReader: pipeReader,
+		write: func() {
+			done := make(chan struct{})
+			if ctx.Done() != nil {
+				// the abandoned request unblocks the writer
+				go func() {
+					select {
+					case <-ctx.Done():
+						_ = pipeWriter.CloseWithError(ctx.Err())
+					case <-done:
+					}
+				}()
+			}
+			go func() {
+				defer close(done)
+				counter := &countingWriter{w: pipeWriter, total: total, progress: b.progress}
+				_ = pipeWriter.CloseWithError(b.writeParts(counter))
+			}()
+		},
+		abort: b.closeParts,
+	}
+}
+
+// closeParts closes the part readers that are closers.
+func (b *multipartBuilderImpl) closeParts() {
+	for _, part := range b.parts {
+		if closer, ok := part.content.(io.Closer); ok {
+			_ = closer.Close()
+		}
+	}
+}
+
+func (b *multipartBuilderImpl) writeParts(w io.Writer) (err error) {
+	defer b.closeParts()
+
+	writer := multipart.NewWriter(w)
+	if err = writer.SetBoundary(b.boundary); err != nil {
+		return
+	}
+	for _, part := range b.parts {
+		var (
+			partWriter io.Writer
+			written    int64
+		)
+		if partWriter, err = writer.CreatePart(part.header); err != nil {
+			return
+		} else if written, err = io.Copy(partWriter, part.content); err != nil {
+			return
+		} else if part.size >= 0 && written != part.size {
+			return errors.New("multipart part size mismatch: declared " + strconv.FormatInt(part.size, 10) + ", written " + strconv.FormatInt(written, 10))
+		}
+	}
+	return writer.Close()
+}
+
+func (b *multipartBuilderImpl) NewRequest(ctx context.Context, method, url string) (*http.Request, error) {
+	length := b.ContentLength()
+	body := b.build(ctx, length)
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		_ = body.Close()
+		return nil, err
+	}
+	req.Header.Set(eighty.ContentTypeHeader, b.ContentType())
+	req.ContentLength = length
+	return req, nil
+}
@@ -0,0 +1,160 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// closeTracker is a part reader that records its closing.
+type closeTracker struct {
+	io.Reader
+	closed int32
+}
+
+func (c *closeTracker) Close() error {
+	atomic.StoreInt32(&c.closed, 1)
+	return nil
+}
+
+func (c *closeTracker) isClosed() bool { return atomic.LoadInt32(&c.closed) == 1 }
+
+func TestMultipartBuilderRequest(t *testing.T) {
+	type receivedPart struct {
+		name, fileName, contentType, content string
+	}
+	var received []receivedPart
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength < 0 {
+			t.Error("the request has no Content-Length")
+		}
+		reader, err := r.MultipartReader()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Error(err)
+				return
+			}
+			content, _ := io.ReadAll(part)
+			received = append(received, receivedPart{part.FormName(), part.FileName(), part.Header.Get("Content-Type"), string(content)})
+		}
+	}))
+	defer srv.Close()
+
+	file := &closeTracker{Reader: strings.NewReader("file content")}
+	var progress []int64
+	builder := NewMultipartBuilder().
+		AddField(`say "hi"\now`, "hello").
+		AddFile("upload", "보고서 2024.txt", "text/plain", int64(len("file content")), file).
+		OnProgress(func(written, total int64) {
+			if written == total {
+				progress = append(progress, total)
+			}
+		})
+	req, err := builder.NewRequest(context.Background(), http.MethodPost, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := NewClient(0, time.Second, time.Second, time.Second, 1, false, "").Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+
+	want := []receivedPart{
+		{name: `say "hi"\now`, content: "hello"},
+		{name: "upload", fileName: "보고서 2024.txt", contentType: "text/plain", content: "file content"},
+	}
+	if len(received) != len(want) {
+		t.Fatalf("received %+v", received)
+	}
+	for i := range want {
+		if received[i] != want[i] {
+			t.Errorf("part %d is %+v, want %+v", i, received[i], want[i])
+		}
+	}
+	if !file.isClosed() {
+		t.Error("the file was not closed")
+	} else if len(progress) != 1 || progress[0] != req.ContentLength {
+		t.Errorf("progress completed with %v, want the content length %d", progress, req.ContentLength)
+	}
+}
+
+func TestMultipartBuilderBody(t *testing.T) {
+	builder := NewMultipartBuilder().AddField("name", "value").AddFile("file", "a.bin", "", 3, strings.NewReader("abc"))
+	body := builder.Build()
+	encoded, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	} else if int64(len(encoded)) != builder.ContentLength() {
+		t.Errorf("wrote %d bytes, want the content length %d", len(encoded), builder.ContentLength())
+	}
+	_, params, err := mime.ParseMediaType(builder.ContentType())
+	if err != nil {
+		t.Fatal(err)
+	}
+	form, err := multipart.NewReader(bytes.NewReader(encoded), params["boundary"]).ReadForm(1 << 10)
+	if err != nil {
+		t.Fatal(err)
+	} else if form.Value["name"][0] != "value" || form.File["file"][0].Header.Get("Content-Type") != "application/octet-stream" {
+		t.Errorf("form %+v", form)
+	}
+
+	if _, err = io.ReadAll(builder.Build()); err == nil {
+		t.Error("the body was built twice")
+	}
+	if length := NewMultipartBuilder().AddFile("file", "a.bin", "", -1, strings.NewReader("abc")).ContentLength(); length != -1 {
+		t.Errorf("content length %d with an unknown part size", length)
+	}
+	if _, err = io.ReadAll(NewMultipartBuilder().AddFile("file", "a.bin", "", 5, strings.NewReader("abc")).Build()); err == nil {
+		t.Error("the part size mismatch was not reported")
+	}
+}
+
+func TestMultipartBuilderAbandoned(t *testing.T) {
+	t.Run("closed unread", func(t *testing.T) {
+		file := &closeTracker{Reader: strings.NewReader("content")}
+		body := NewMultipartBuilder().AddFile("file", "a.txt", "", 7, file).Build()
+		if err := body.Close(); err != nil {
+			t.Fatal(err)
+		} else if !file.isClosed() {
+			t.Error("the file of the unread body was not closed")
+		}
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		file := &closeTracker{Reader: bytes.NewReader(make([]byte, 1<<20))}
+		req, err := NewMultipartBuilder().AddFile("file", "a.bin", "", 1<<20, file).NewRequest(ctx, http.MethodPost, "http://example.com/upload")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = req.Body.Read(make([]byte, 16)); err != nil {
+			t.Fatal(err)
+		}
+		cancel()
+		for deadline := time.Now().Add(time.Second); !file.isClosed(); time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatal("the writer of the abandoned body did not stop")
+			}
+		}
+		if _, err = io.ReadAll(req.Body); !errors.Is(err, context.Canceled) {
+			t.Errorf("read %v, want %v", err, context.Canceled)
+		}
+	})
+}
@@ -0,0 +1,261 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// the states of a tracked connection
+const (
+	connActive int32 = iota
+	connIdle
+	connClosing
+)
+
+type (
+	// HostPoolStats is a snapshot of the connection pool of a host.
+	HostPoolStats struct {
+		// Host is the dialed address, the proxy address for proxied requests.
+		Host string
+		// Active is the number of connections serving a request.
+		Active int
+		// Idle is the number of connections waiting in the pool.
+		Idle int
+		// Dialing is the number of connections being established.
+		Dialing int
+		// Requests is the number of connections handed to requests.
+		Requests int64
+		// Reused is the number of requests served by a pooled connection.
+		Reused int64
+		// ReuseRatio is Reused divided by Requests.
+		ReuseRatio float64
+		// WaitTotal is the accumulated time requests waited for a connection.
+		WaitTotal time.Duration
+		// WaitAverage is WaitTotal divided by Requests.
+		WaitAverage time.Duration
+	}
+
+	// PoolInspector reports and manages the connections of the transport built by NewRoundTripper.
+	PoolInspector interface {
+		// PoolStats returns the connection pool snapshots sorted by host.
+		// A host is forgotten, together with its counters, once its last connection closes.
+		PoolStats() []HostPoolStats
+		// CloseIdleConnectionsFor closes the idle connections of the host ("host:port")
+		// and returns the number of closed connections.
+		CloseIdleConnectionsFor(host string) int
+	}
+
+	trackedConn struct {
+		net.Conn
+		tracker *poolTracker
+		host    string
+		state   int32
+		closed  int32
+	}
+
+	hostPool struct {
+		conns    map[*trackedConn]struct{}
+		dialing  int
+		requests int64
+		reused   int64
+		wait     time.Duration
+	}
+
+	poolTracker struct {
+		lock  sync.Mutex
+		hosts map[string]*hostPool
+	}
+)
+
+// InspectPool returns the PoolInspector of the transport built by NewRoundTripper,
+// or of the Client that uses such a transport.
+func InspectPool(rt http.RoundTripper) (inspector PoolInspector, ok bool) {
+	if cli, isClient := rt.(Client); isClient {
+		rt = cli.roundTripper()
+	}
+	inspector, ok = rt.(PoolInspector)
+	return
+}
+
+func newPoolTracker() *poolTracker {
+	return &poolTracker{hosts: make(map[string]*hostPool)}
+}
+
+// Write refuses a connection being closed as idle, the transport then retries the request on another connection.
+func (c *trackedConn) Write(b []byte) (int, error) {
+	if atomic.LoadInt32(&c.state) == connClosing {
+		return 0, net.ErrClosed
+	}
+	return c.Conn.Write(b)
+}
+
+func (c *trackedConn) Close() error {
+	if atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		c.tracker.remove(c)
+	}
+	return c.Conn.Close()
+}
+
+// hostLocked returns the pool of the host, the lock must be held.
+func (t *poolTracker) hostLocked(host string) *hostPool {
+	pool, ok := t.hosts[host]
+	if !ok {
+		pool = &hostPool{conns: make(map[*trackedConn]struct{})}
+		t.hosts[host] = pool
+	}
+	return pool
+}
+
+// releaseLocked forgets the pool of the host once it has no connections left, the lock must be held.
+func (t *poolTracker) releaseLocked(host string, pool *hostPool) {
+	if len(pool.conns) == 0 && pool.dialing == 0 {
+		delete(t.hosts, host)
+	}
+}
+
+// dialContext wraps dial so that the dialed connections are tracked by their address.
+func (t *poolTracker) dialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		t.lock.Lock()
+		t.hostLocked(addr).dialing++
+		t.lock.Unlock()
+
+		conn, err := dial(ctx, network, addr)
+
+		t.lock.Lock()
+		defer t.lock.Unlock()
+		pool := t.hostLocked(addr)
+		pool.dialing--
+		if err != nil {
+			t.releaseLocked(addr, pool)
+			return nil, err
+		}
+		tracked := &trackedConn{Conn: conn, tracker: t, host: addr}
+		pool.conns[tracked] = struct{}{}
+		return tracked, nil
+	}
+}
+
+func (t *poolTracker) remove(conn *trackedConn) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if pool, ok := t.hosts[conn.host]; ok {
+		delete(pool.conns, conn)
+		t.releaseLocked(conn.host, pool)
+	}
+}
+
+// tracked returns the trackedConn under the TLS layer of the connection.
+func tracked(conn net.Conn) (*trackedConn, bool) {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	trackedConn, ok := conn.(*trackedConn)
+	return trackedConn, ok
+}
+
+func (t *poolTracker) gotConn(info httptrace.GotConnInfo, wait time.Duration) {
+	conn, ok := tracked(info.Conn)
+	if !ok {
+		return
+	}
+	// a connection being closed as idle refuses the request, see trackedConn.Write
+	if !atomic.CompareAndSwapInt32(&conn.state, connIdle, connActive) && atomic.LoadInt32(&conn.state) == connClosing {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	pool := t.hostLocked(conn.host)
+	pool.requests++
+	pool.wait += wait
+	if info.Reused {
+		pool.reused++
+	}
+}
+
+func (t *poolTracker) putIdleConn(conn net.Conn, err error) {
+	if trackedConn, ok := tracked(conn); ok && err == nil {
+		atomic.CompareAndSwapInt32(&trackedConn.state, connActive, connIdle)
+	}
+}
+
+// trace returns a httptrace.ClientTrace that reports the connection usage of a request.
+func (t *poolTracker) trace() *httptrace.ClientTrace {
+	var (
+		requestedAt time.Time
+		got         atomic.Value
+	)
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			requestedAt = time.Now()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			got.Store(info.Conn)
+			t.gotConn(info, time.Since(requestedAt))
+		},
+		PutIdleConn: func(err error) {
+			if conn, ok := got.Load().(net.Conn); ok {
+				t.putIdleConn(conn, err)
+			}
+		},
+	}
+}
+
+func (t *poolTracker) PoolStats() (stats []HostPoolStats) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for host, pool := range t.hosts {
+		stat := HostPoolStats{
+			Host:      host,
+			Dialing:   pool.dialing,
+			Requests:  pool.requests,
+			Reused:    pool.reused,
+			WaitTotal: pool.wait,
+		}
+		for conn := range pool.conns {
+			switch atomic.LoadInt32(&conn.state) {
+			case connIdle:
+				stat.Idle++
+			case connActive:
+				stat.Active++
+			}
+		}
+		if pool.requests > 0 {
+			stat.ReuseRatio = float64(pool.reused) / float64(pool.requests)
+			stat.WaitAverage = pool.wait / time.Duration(pool.requests)
+		}
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Host < stats[j].Host })
+	return
+}
+
+func (t *poolTracker) CloseIdleConnectionsFor(host string) (closed int) {
+	t.lock.Lock()
+	var idle []*trackedConn
+	if pool, ok := t.hosts[host]; ok {
+		for conn := range pool.conns {
+			// a connection handed to a request meanwhile stays open
+			if atomic.CompareAndSwapInt32(&conn.state, connIdle, connClosing) {
+				idle = append(idle, conn)
+			}
+		}
+	}
+	t.lock.Unlock()
+
+	// the transport drops a pooled connection as soon as it notices the close
+	for _, conn := range idle {
+		if conn.Close() == nil {
+			closed++
+		}
+	}
+	return
+}
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+type (
+	// ProxySelector returns the proxy URL for the request, or nil for a direct connection.
+	// The http, https, socks5 and socks5h proxy schemes are supported,
+	// credentials in the proxy URL are sent to the proxy.
+	ProxySelector = func(req *http.Request) (*url.URL, error)
+
+	proxyOverrideKey struct{}
+	proxyOverride    struct {
+		proxy *url.URL
+	}
+
+	// noProxyEntry is a host, an IP address or a network of noProxy, limited to the port if not empty.
+	noProxyEntry struct {
+		domain  string
+		ip      net.IP
+		network *net.IPNet
+		port    string
+	}
+	noProxyMatcher struct {
+		any     bool
+		entries []noProxyEntry
+	}
+)
+
+// WithProxyOverride returns a context that makes the requests bypass the ProxySelector.
+// A nil proxy forces a direct connection.
+func WithProxyOverride(ctx context.Context, proxy *url.URL) context.Context {
+	return context.WithValue(ctx, proxyOverrideKey{}, &proxyOverride{proxy: proxy})
+}
+
+// WithProxy sets the ProxySelector of NewRoundTripper.
+// The fasthttp based transport does not support proxies.
+func WithProxy(selector ProxySelector) DialOption {
+	return func(d *dialer) {
+		d.proxySelector = selector
+	}
+}
+
+// NewProxySelector returns a ProxySelector that sends http requests through httpProxy and
+// https requests through httpsProxy, a nil proxy means a direct connection.
+// Hosts that match noProxy are always connected directly. An entry of noProxy is "*",
+// an IP address, a CIDR, or a domain that also matches its subdomains, optionally with a port
+// such as "10.0.0.1:8080", "[::1]:8080" or "example.com:443".
+func NewProxySelector(httpProxy, httpsProxy *url.URL, noProxy ...string) ProxySelector {
+	matcher := newNoProxyMatcher(noProxy)
+	return func(req *http.Request) (*url.URL, error) {
+		var proxy *url.URL
+		// the port of the noProxy entries is compared with the effective port of the request
+		port := req.URL.Port()
+		switch req.URL.Scheme {
+		case "https", "wss":
+			proxy = httpsProxy
+			if len(port) == 0 {
+				port = "443"
+			}
+		default:
+			proxy = httpProxy
+			if len(port) == 0 {
+				port = "80"
+			}
+		}
+		if proxy == nil || matcher.match(req.URL.Hostname(), port) {
+			return nil, nil
+		}
+		return proxy, nil
+	}
+}
+
+// ProxyFromEnvironment returns a ProxySelector configured by the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+// environment variables or their lowercase versions.
+func ProxyFromEnvironment() (ProxySelector, error) {
+	getenv := func(name string) string {
+		if value := os.Getenv(name); len(value) > 0 {
+			return value
+		}
+		return os.Getenv(strings.ToLower(name))
+	}
+	parse := func(value string) (*url.URL, error) {
+		if len(value) == 0 {
+			return nil, nil
+		} else if !strings.Contains(value, "://") {
+			value = "http://" + value
+		}
+		return url.Parse(value)
+	}
+
+	httpProxy, err := parse(getenv("HTTP_PROXY"))
+	if err != nil {
+		return nil, err
+	}
+	httpsProxy, err := parse(getenv("HTTPS_PROXY"))
+	if err != nil {
+		return nil, err
+	}
+	return NewProxySelector(httpProxy, httpsProxy, strings.Split(getenv("NO_PROXY"), ",")...), nil
+}
+
+func newNoProxyMatcher(entries []string) *noProxyMatcher {
+	m := &noProxyMatcher{}
+	for _, value := range entries {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if len(value) == 0 {
+			continue
+		} else if value == "*" {
+			m.any = true
+			continue
+		}
+
+		var entry noProxyEntry
+		// a bare IPv6 address does not split, as it has too many colons
+		if host, port, err := net.SplitHostPort(value); err == nil {
+			value, entry.port = host, port
+		}
+		value = strings.Trim(value, "[]")
+		if _, network, err := net.ParseCIDR(value); err == nil {
+			entry.network = network
+		} else if ip := net.ParseIP(value); ip != nil {
+			entry.ip = ip
+		} else {
+			entry.domain = strings.TrimPrefix(strings.TrimPrefix(value, "*"), ".")
+		}
+		m.entries = append(m.entries, entry)
+	}
+	return m
+}
+
+func (m *noProxyMatcher) match(host, port string) bool {
+	if m.any {
+		return true
+	}
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	for _, entry := range m.entries {
+		if len(entry.port) > 0 && entry.port != port {
+			continue
+		}
+		switch {
+		case entry.network != nil:
+			if ip != nil && entry.network.Contains(ip) {
+				return true
+			}
+		case entry.ip != nil:
+			if ip != nil && entry.ip.Equal(ip) {
+				return true
+			}
+		case ip == nil:
+			if host == entry.domain || strings.HasSuffix(host, "."+entry.domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// proxyFor returns the proxy URL of the request, honoring the WithProxyOverride context.
+// The requests to the unix-domain sockets are never proxied.
+func (d *dialer) proxyFor(req *http.Request) (*url.URL, error) {
+	if _, ok := d.unixSockets[req.URL.Hostname()]; ok {
+		return nil, nil
+	} else if override, ok := req.Context().Value(proxyOverrideKey{}).(*proxyOverride); ok {
+		return override.proxy, nil
+	} else if d.proxySelector == nil {
+		return nil, nil
+	}
+	return d.proxySelector(req)
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestProxySelectorNoProxy(t *testing.T) {
+	proxy := &url.URL{Scheme: "http", Host: "proxy.internal:3128"}
+	selector := NewProxySelector(proxy, proxy,
+		"localhost", ".corp.example.com", "*.svc.example.org", "example.net:8443",
+		"10.0.0.1", "10.0.0.2:8080", "192.168.0.0/16", "::1", "[fd00::1]:8080", "[fd00::2]", " ",
+	)
+
+	tests := []struct {
+		url         string
+		wantProxied bool
+	}{
+		{url: "http://localhost/"},
+		{url: "http://LOCALHOST:8080/"},
+		{url: "http://notlocalhost/", wantProxied: true},
+		{url: "https://corp.example.com/"},
+		{url: "https://api.corp.example.com/"},
+		{url: "https://example.com/", wantProxied: true},
+		{url: "http://svc.example.org/"},
+		{url: "http://db.svc.example.org/"},
+		{url: "https://example.net:8443/"},
+		{url: "https://www.example.net:8443/"},
+		{url: "https://example.net/", wantProxied: true},
+		{url: "http://10.0.0.1:9000/"},
+		{url: "http://10.0.0.2:8080/"},
+		{url: "http://10.0.0.2/", wantProxied: true},
+		{url: "http://192.168.3.4/"},
+		{url: "http://192.169.0.1/", wantProxied: true},
+		{url: "http://[::1]:8080/"},
+		{url: "http://[fd00::1]:8080/"},
+		{url: "http://[fd00::1]/", wantProxied: true},
+		{url: "https://[fd00::2]/"},
+		{url: "http://[fd00::3]/", wantProxied: true},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, test.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			selected, err := selector(req)
+			if err != nil {
+				t.Fatal(err)
+			} else if proxied := selected != nil; proxied != test.wantProxied {
+				t.Errorf("proxied %t, want %t", proxied, test.wantProxied)
+			}
+		})
+	}
+}
+
+func TestProxyFor(t *testing.T) {
+	proxy := &url.URL{Scheme: "http", Host: "proxy.internal:3128"}
+	d := newDialer(0, 0, WithUnixSocket("docker", "/var/run/docker.sock"), WithProxy(NewProxySelector(proxy, proxy)))
+
+	tests := []struct {
+		name      string
+		url       string
+		ctx       context.Context
+		wantProxy *url.URL
+	}{
+		{name: "selected", url: "http://api.example.com/", ctx: context.Background(), wantProxy: proxy},
+		{name: "unix socket", url: "http://docker/containers/json", ctx: context.Background()},
+		{name: "unix scheme", url: UnixScheme + "://docker/containers/json", ctx: context.Background()},
+		{name: "direct override", url: "http://api.example.com/", ctx: WithProxyOverride(context.Background(), nil)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(test.ctx, http.MethodGet, test.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			selected, err := d.proxyFor(req)
+			if err != nil {
+				t.Fatal(err)
+			} else if selected != test.wantProxy {
+				t.Errorf("proxy %v, want %v", selected, test.wantProxy)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+var (
+	// ErrRedirectCrossOrigin is returned when the same-origin-only policy meets a redirect to another origin.
+	ErrRedirectCrossOrigin = errors.New("redirect to another origin refused")
+	// ErrRedirectDowngrade is returned when a redirect from https to http is refused.
+	ErrRedirectDowngrade = errors.New("redirect from https to http refused")
+
+	defaultSensitiveHeaders = []string{authorizationHeader, "Cookie", "Proxy-Authorization"}
+)
+
+type (
+	// RedirectPolicy is a redirect checker for the Client.
+	RedirectPolicy interface {
+		// CheckRedirect implements the http.Client CheckRedirect function.
+		CheckRedirect(req *http.Request, via []*http.Request) error
+		prepare(req *http.Request) (*http.Request, error)
+		complete(res *http.Response)
+	}
+
+	redirectChainKey struct{}
+	redirectChain    struct {
+		lock sync.Mutex
+		urls []*url.URL
+	}
+
+	redirectPolicyImpl struct {
+		maxHops          int
+		sameOriginOnly   bool
+		refuseDowngrade  bool
+		replayBodyLimit  int64
+		sensitiveHeaders []string
+		onComplete       func(res *http.Response, chain []*url.URL)
+	}
+)
+
+// NewRedirectPolicy returns a RedirectPolicy.
+// maxHops limits the number of redirects, sameOriginOnly refuses redirects to another origin and
+// refuseDowngrade refuses redirects from https to http.
+// Request bodies without GetBody are buffered up to replayBodyLimit bytes,
+// so 307 and 308 redirects can replay them.
+// onComplete, if not nil, receives the final response with the visited URLs.
+// sensitiveHeaders are stripped on cross-host hops, Authorization, Cookie and Proxy-Authorization if empty.
+func NewRedirectPolicy(
+	maxHops int,
+	sameOriginOnly bool,
+	refuseDowngrade bool,
+	replayBodyLimit int64,
+	onComplete func(res *http.Response, chain []*url.URL),
+	sensitiveHeaders ...string,
+) RedirectPolicy {
+	if len(sensitiveHeaders) == 0 {
+		sensitiveHeaders = defaultSensitiveHeaders
+	}
+	return &redirectPolicyImpl{
+		maxHops:          maxHops,
+		sameOriginOnly:   sameOriginOnly,
+		refuseDowngrade:  refuseDowngrade,
+		replayBodyLimit:  replayBodyLimit,
+		sensitiveHeaders: sensitiveHeaders,
+		onComplete:       onComplete,
+	}
+}
+
+// RedirectChain returns the URLs visited until the response, starting with the original request URL.
+// It returns nil if the Client has no RedirectPolicy.
+func RedirectChain(res *http.Response) []*url.URL {
+	if res == nil || res.Request == nil {
+		return nil
+	}
+	if chain, ok := res.Request.Context().Value(redirectChainKey{}).(*redirectChain); ok {
+		chain.lock.Lock()
+		defer chain.lock.Unlock()
+		return append([]*url.URL(nil), chain.urls...)
+	}
+	return nil
+}
+
+func (p *redirectPolicyImpl) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > p.maxHops {
+		return errors.New("stopped after " + strconv.Itoa(p.maxHops) + " redirects")
+	}
+	var (
+		original = via[0].URL
+		previous = via[len(via)-1].URL
+	)
+	if p.sameOriginOnly && (req.URL.Scheme != original.Scheme || req.URL.Host != original.Host) {
+		return ErrRedirectCrossOrigin
+	} else if p.refuseDowngrade && previous.Scheme == "https" && req.URL.Scheme == "http" {
+		return ErrRedirectDowngrade
+	}
+	if req.URL.Hostname() != original.Hostname() {
+		for _, name := range p.sensitiveHeaders {
+			req.Header.Del(name)
+		}
+	}
+	if chain, ok := req.Context().Value(redirectChainKey{}).(*redirectChain); ok {
+		chain.lock.Lock()
+		chain.urls = append(chain.urls, req.URL)
+		chain.lock.Unlock()
+	}
+	return nil
+}
+
+func (p *redirectPolicyImpl) prepare(req *http.Request) (*http.Request, error) {
+	chain := &redirectChain{urls: []*url.URL{req.URL}}
+	req = req.WithContext(context.WithValue(req.Context(), redirectChainKey{}, chain))
+	if req.GetBody != nil || req.Body == nil || req.Body == http.NoBody || p.replayBodyLimit <= 0 {
+		return req, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, p.replayBodyLimit+1))
+	if err != nil {
+		_ = req.Body.Close()
+		return nil, err
+	}
+	if int64(len(body)) > p.replayBodyLimit {
+		// too large to replay, send the rest as a stream
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+		return req, nil
+	}
+	_ = req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	return req, nil
+}
+
+func (p *redirectPolicyImpl) complete(res *http.Response) {
+	if p.onComplete != nil {
+		p.onComplete(res, RedirectChain(res))
+	}
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedirectPolicyCheckRedirect(t *testing.T) {
+	tests := []struct {
+		name           string
+		policy         RedirectPolicy
+		via            []string
+		target         string
+		wantErr        error
+		wantAnyErr     bool
+		wantAuthorized bool
+	}{
+		{name: "same host", policy: NewRedirectPolicy(3, false, false, 0, nil),
+			via: []string{"https://example.com/a"}, target: "https://example.com/b", wantAuthorized: true},
+		{name: "other port", policy: NewRedirectPolicy(3, false, false, 0, nil),
+			via: []string{"https://example.com/a"}, target: "https://example.com:8443/b", wantAuthorized: true},
+		{name: "other host", policy: NewRedirectPolicy(3, false, false, 0, nil),
+			via: []string{"https://example.com/a"}, target: "https://cdn.example.net/b"},
+		{name: "too many hops", policy: NewRedirectPolicy(1, false, false, 0, nil),
+			via: []string{"https://example.com/a", "https://example.com/b"}, target: "https://example.com/c", wantAnyErr: true},
+		{name: "cross origin", policy: NewRedirectPolicy(3, true, false, 0, nil),
+			via: []string{"https://example.com/a"}, target: "https://cdn.example.net/b", wantErr: ErrRedirectCrossOrigin},
+		{name: "scheme change", policy: NewRedirectPolicy(3, true, false, 0, nil),
+			via: []string{"http://example.com/a"}, target: "https://example.com/b", wantErr: ErrRedirectCrossOrigin},
+		{name: "downgrade", policy: NewRedirectPolicy(3, false, true, 0, nil),
+			via: []string{"https://example.com/a"}, target: "http://example.com/b", wantErr: ErrRedirectDowngrade},
+		{name: "downgrade allowed", policy: NewRedirectPolicy(3, false, false, 0, nil),
+			via: []string{"https://example.com/a"}, target: "http://example.com/b", wantAuthorized: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			via := make([]*http.Request, len(test.via))
+			for i, rawURL := range test.via {
+				via[i] = httptest.NewRequest(http.MethodGet, rawURL, nil)
+			}
+			req := httptest.NewRequest(http.MethodGet, test.target, nil)
+			req.Header.Set(authorizationHeader, "Bearer token")
+			req.Header.Set("Cookie", "session=1")
+
+			err := test.policy.CheckRedirect(req, via)
+			if test.wantErr != nil || test.wantAnyErr {
+				if err == nil || (test.wantErr != nil && err != test.wantErr) {
+					t.Errorf("got %v, want %v", err, test.wantErr)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if authorized := len(req.Header.Get(authorizationHeader)) > 0; authorized != test.wantAuthorized {
+				t.Errorf("kept Authorization %t, want %t", authorized, test.wantAuthorized)
+			} else if cookie := len(req.Header.Get("Cookie")) > 0; cookie != test.wantAuthorized {
+				t.Errorf("kept Cookie %t, want %t", cookie, test.wantAuthorized)
+			}
+		})
+	}
+}
+
+func TestRedirectPolicyFollow(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/start":
+			http.Redirect(w, r, "/moved", http.StatusFound)
+		case "/moved":
+			http.Redirect(w, r, "/final", http.StatusMovedPermanently)
+		case "/post":
+			http.Redirect(w, r, "/echo", http.StatusTemporaryRedirect)
+		case "/echo":
+			body, _ := io.ReadAll(r.Body)
+			_, _ = w.Write(body)
+		default:
+			_, _ = io.WriteString(w, "final")
+		}
+	}))
+	defer srv.Close()
+
+	var completed []*url.URL
+	cli := NewClient(0, time.Second, time.Second, time.Second, 1, false, "").
+		WithRedirectPolicy(NewRedirectPolicy(3, true, true, 16, func(_ *http.Response, chain []*url.URL) {
+			completed = chain
+		}))
+
+	res, err := cli.Get(srv.URL + "/start")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	chain := RedirectChain(res)
+	if len(chain) != 3 || chain[0].Path != "/start" || chain[1].Path != "/moved" || chain[2].Path != "/final" {
+		t.Errorf("chain %v", chain)
+	} else if len(completed) != len(chain) {
+		t.Errorf("completed with the chain %v", completed)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "replayed", body: "payload", wantStatus: http.StatusOK, wantBody: "payload"},
+		{name: "over the replay limit", body: strings.Repeat("x", 32), wantStatus: http.StatusTemporaryRedirect},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// the body hides its length and GetBody, like a stream
+			req, err := http.NewRequest(http.MethodPost, srv.URL+"/post", io.NopCloser(strings.NewReader(test.body)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := cli.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			body, _ := io.ReadAll(res.Body)
+			if res.StatusCode != test.wantStatus {
+				t.Errorf("status %d, want %d", res.StatusCode, test.wantStatus)
+			} else if len(test.wantBody) > 0 && string(body) != test.wantBody {
+				t.Errorf("body %q, want %q", body, test.wantBody)
+			}
+		})
+	}
+}
@@ -1,8 +1,8 @@
 package client
 
 import (
-	"net"
 	"net/http"
+	"net/http/httptrace"
 	"time"
 )
 
@@ -12,10 +12,16 @@ const (
 
 type predefinedHeaderTransport struct {
 	useragentName string
+	dialer        *dialer
+	*poolTracker
 	http.Transport
 }
 
 func (pht *predefinedHeaderTransport) RoundTrip(req *http.Request) (res *http.Response, err error) {
+	if req, err = pht.dialer.rewriteUnixScheme(req); err != nil {
+		return
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), pht.trace()))
 	req.Close = pht.DisableKeepAlives
 	req.Header.Set(userAgentHeader, pht.useragentName)
 	res, err = pht.Transport.RoundTrip(req)
@@ -23,6 +29,7 @@ func (pht *predefinedHeaderTransport) RoundTrip(req *http.Request) (res *http.Re
 }
 
 // NewRoundTripper returns a http.RoundTripper that has some tunable parameters.
+// The dialer can be customized with dialOptions, and the connection pool is reported by InspectPool.
 func NewRoundTripper(
 	keepaliveDuration time.Duration,
 	connectTimeout time.Duration,
@@ -30,21 +37,24 @@ func NewRoundTripper(
 	idleConnectionTimeout time.Duration,
 	maxIdleConnections int,
 	serverName string,
+	dialOptions ...DialOption,
 ) http.RoundTripper {
 
 	keepaliveDisabled := keepaliveDuration == 0
-	dialer := &net.Dialer{
-		Timeout:   connectTimeout,
-		KeepAlive: keepaliveDuration,
-	}
+	dialer := newDialer(connectTimeout, keepaliveDuration, dialOptions...)
+	tracker := newPoolTracker()
 
 	return &predefinedHeaderTransport{
 		useragentName: serverName,
+		dialer:        dialer,
+		poolTracker:   tracker,
 		Transport: http.Transport{
 			DisableKeepAlives:     keepaliveDisabled,
 			DisableCompression:    true,
 			MaxIdleConnsPerHost:   maxIdleConnections,
-			DialContext:           dialer.DialContext,
+			DialContext:           tracker.dialContext(dialer.DialContext),
+			TLSClientConfig:       dialer.tlsConfig,
+			Proxy:                 dialer.proxyFor,
 			MaxIdleConns:          maxIdleConnections,
 			IdleConnTimeout:       idleConnectionTimeout,
 			ResponseHeaderTimeout: responseHeaderTimeout,
@@ -0,0 +1,173 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"github.com/valyala/fasthttp"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type fasthttpTransport struct {
+	keepaliveDisabled bool
+	client            fasthttp.Client
+}
+
+// RoundTrip implements the http.RoundTripper interface with the fasthttp.Client.
+func (ft *fasthttpTransport) RoundTrip(req *http.Request) (res *http.Response, err error) {
+	ctx := req.Context()
+	if err = ctx.Err(); err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return
+	}
+	req.Close = ft.keepaliveDisabled
+
+	var (
+		fastReq  = fasthttp.AcquireRequest()
+		fastResp = fasthttp.AcquireResponse()
+		exchange = make(chan error, 1)
+	)
+	ft.copyRequest(fastReq, req)
+	go func() {
+		if deadline, ok := ctx.Deadline(); ok {
+			exchange <- ft.client.DoDeadline(fastReq, fastResp, deadline)
+		} else {
+			exchange <- ft.client.Do(fastReq, fastResp)
+		}
+	}()
+
+	select {
+	case err = <-exchange:
+	case <-ctx.Done():
+		// the fasthttp.Client cannot interrupt the exchange, it ends in the background within the client timeouts
+		go func() {
+			<-exchange
+			ft.release(req, fastReq, fastResp)
+		}()
+		return nil, ctx.Err()
+	}
+	if err == nil {
+		res = ft.copyResponse(fastResp, req)
+	}
+	ft.release(req, fastReq, fastResp)
+	return
+}
+
+// release closes the request body and returns the fasthttp objects of a finished exchange to their pools.
+func (ft *fasthttpTransport) release(req *http.Request, fastReq *fasthttp.Request, fastResp *fasthttp.Response) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+	fasthttp.ReleaseRequest(fastReq)
+	fasthttp.ReleaseResponse(fastResp)
+}
+
+// CloseIdleConnections closes any connections which are now sitting idle.
+func (ft *fasthttpTransport) CloseIdleConnections() {
+	ft.client.CloseIdleConnections()
+}
+
+func (ft *fasthttpTransport) copyRequest(dst *fasthttp.Request, src *http.Request) {
+	dst.SetRequestURI(src.URL.String())
+	dst.Header.SetMethod(src.Method)
+	for key, values := range src.Header {
+		for _, value := range values {
+			dst.Header.Add(key, value)
+		}
+	}
+	if len(src.Host) > 0 {
+		// the URL host is still dialed
+		dst.Header.SetHost(src.Host)
+		dst.UseHostHeader = true
+	}
+	if len(ft.client.Name) > 0 {
+		dst.Header.SetUserAgent(ft.client.Name)
+	}
+	if src.Close {
+		dst.SetConnectionClose()
+	}
+	if src.Body != nil && src.Body != http.NoBody {
+		size := int(src.ContentLength)
+		if size == 0 {
+			// unknown length, sent with chunked transfer encoding
+			size = -1
+		}
+		dst.SetBodyStream(src.Body, size)
+	}
+}
+
+func (ft *fasthttpTransport) copyResponse(src *fasthttp.Response, req *http.Request) *http.Response {
+	var (
+		code   = src.StatusCode()
+		header = make(http.Header)
+		body   = append([]byte(nil), src.Body()...)
+	)
+	src.Header.VisitAll(func(key, value []byte) {
+		header.Add(string(key), string(value))
+	})
+
+	contentLength := int64(len(body))
+	if src.SkipBody {
+		contentLength = int64(src.Header.ContentLength())
+	}
+
+	return &http.Response{
+		Status:        strconv.Itoa(code) + " " + http.StatusText(code),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: contentLength,
+		Close:         src.ConnectionClose(),
+		Request:       req,
+	}
+}
+
+// NewFasthttpRoundTripper returns a http.RoundTripper backed by the fasthttp.Client.
+// It takes the same tunable parameters as NewRoundTripper.
+// Since the fasthttp.Client keeps every connection it opened for reuse,
+// maxIdleConnections limits the number of connections per host.
+// The dialer can be customized with dialOptions, the UnixScheme and proxies are not supported.
+//
+// The fasthttp.Client reads the whole response body into memory before RoundTrip returns, and
+// responseHeaderTimeout bounds the read of the whole response rather than of its header.
+// Streamed bodies, such as server-sent events, or large downloads with progress and resume, are not supported;
+// use NewRoundTripper for them.
+//
+// RoundTrip returns once the request context is done, while the exchange ends in the background
+// within the client timeouts. As the fasthttp.Client dials without a request context,
+// a dial is bounded by connectTimeout only, and the connection it opens is kept for the next request.
+func NewFasthttpRoundTripper(
+	keepaliveDuration time.Duration,
+	connectTimeout time.Duration,
+	responseHeaderTimeout time.Duration,
+	idleConnectionTimeout time.Duration,
+	maxIdleConnections int,
+	serverName string,
+	dialOptions ...DialOption,
+) http.RoundTripper {
+
+	keepaliveDisabled := keepaliveDuration == 0
+	dialer := newDialer(connectTimeout, keepaliveDuration, dialOptions...)
+
+	return &fasthttpTransport{
+		keepaliveDisabled: keepaliveDisabled,
+		client: fasthttp.Client{
+			Name:                     serverName,
+			NoDefaultUserAgentHeader: len(serverName) == 0,
+			Dial:                     func(addr string) (net.Conn, error) { return dialer.DialContext(context.Background(), "tcp", addr) },
+			TLSConfig:                dialer.tlsConfig,
+			MaxConnsPerHost:          maxIdleConnections,
+			MaxConnWaitTimeout:       dialer.connWaitTimeout,
+			MaxIdleConnDuration:      idleConnectionTimeout,
+			ReadTimeout:              responseHeaderTimeout,
+		},
+	}
+}
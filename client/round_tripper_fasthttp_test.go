@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// newBlockingServer returns a server that answers once release is closed.
+func newBlockingServer(release chan struct{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		_, _ = io.WriteString(w, "done")
+	}))
+}
+
+func TestFasthttpRoundTripperCancel(t *testing.T) {
+	release := make(chan struct{})
+	srv := newBlockingServer(release)
+	defer srv.Close()
+	defer close(release)
+
+	rt := NewFasthttpRoundTripper(0, time.Second, time.Minute, time.Second, 1, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	started := time.Now()
+	if _, err = rt.RoundTrip(req); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	} else if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Fatalf("the canceled request returned after %s", elapsed)
+	}
+}
+
+func TestFasthttpRoundTripperConnWait(t *testing.T) {
+	release := make(chan struct{})
+	srv := newBlockingServer(release)
+	defer srv.Close()
+
+	rt := NewFasthttpRoundTripper(time.Minute, time.Second, time.Minute, time.Minute, 1, "", WithConnWaitTimeout(50*time.Millisecond))
+	busy := make(chan error, 1)
+	go func() {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		res, err := rt.RoundTrip(req)
+		if err == nil {
+			_ = res.Body.Close()
+		}
+		busy <- err
+	}()
+	// let the first request take the only connection
+	time.Sleep(100 * time.Millisecond)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = rt.RoundTrip(req); !errors.Is(err, fasthttp.ErrNoFreeConns) {
+		t.Errorf("got %v, want %v", err, fasthttp.ErrNoFreeConns)
+	}
+	close(release)
+	if err = <-busy; err != nil {
+		t.Fatal(err)
+	}
+}
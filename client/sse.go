@@ -0,0 +1,255 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"github.com/spi-ca/eighty"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxEventLineSize = 1 << 20
+)
+
+var (
+	// ErrNotEventStream is returned when the server does not respond with a text/event-stream.
+	ErrNotEventStream = errors.New("response is not an event stream")
+
+	errEventStreamEnded = errors.New("event stream ended by the server")
+)
+
+type (
+	// EventSource is a Server-Sent Events reader that reconnects with the Last-Event-ID header.
+	EventSource interface {
+		// Subscribe delivers the events to handler until ctx is done, handler returns an error,
+		// the reconnection fails more than the retry limit, or the server responds with 204 No Content.
+		Subscribe(ctx context.Context, handler func(event eighty.ServerSentEvent) error) error
+		// LastEventID returns the id of the last received event.
+		LastEventID() string
+	}
+
+	eventSourceImpl struct {
+		cli        Client
+		url        string
+		maxRetries int
+
+		lock        sync.Mutex
+		lastEventID string
+		retry       time.Duration
+	}
+
+	// eventHandlerError carries the error of the handler out of the connection.
+	eventHandlerError struct {
+		err error
+	}
+
+	// eventParser parses a text/event-stream as specified by the HTML Living Standard.
+	eventParser struct {
+		source    *eventSourceImpl
+		data      strings.Builder
+		hasData   bool
+		eventType string
+		afterCR   bool
+	}
+)
+
+// NewEventSource returns an EventSource of the url.
+// lastEventID resumes a previous subscription if not empty.
+// retry is the reconnection delay until the server advertises one, and
+// maxRetries limits the consecutive failed reconnections, unlimited if negative.
+func NewEventSource(cli Client, url, lastEventID string, retry time.Duration, maxRetries int) EventSource {
+	if retry <= 0 {
+		retry = 3 * time.Second
+	}
+	return &eventSourceImpl{
+		cli:         cli,
+		url:         url,
+		maxRetries:  maxRetries,
+		lastEventID: lastEventID,
+		retry:       retry,
+	}
+}
+
+func (es *eventSourceImpl) LastEventID() string {
+	es.lock.Lock()
+	defer es.lock.Unlock()
+	return es.lastEventID
+}
+
+func (es *eventSourceImpl) Subscribe(ctx context.Context, handler func(event eighty.ServerSentEvent) error) error {
+	for failures := 0; ; {
+		connected, err := es.connect(ctx, handler)
+		if connected {
+			failures = 0
+		} else {
+			failures++
+		}
+		var (
+			handlerErr *eventHandlerError
+			resErr     *ResponseError
+		)
+		switch {
+		case errors.As(err, &handlerErr):
+			return handlerErr.err
+		case err == errEventStreamEnded:
+			return nil
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err == ErrNotEventStream, errors.As(err, &resErr) && resErr.StatusCode < 500:
+			return err
+		case err == io.EOF:
+			// the server closed the stream, reconnect
+		case !connected && es.maxRetries >= 0 && failures > es.maxRetries:
+			return err
+		}
+
+		es.lock.Lock()
+		retry := es.retry
+		es.lock.Unlock()
+		timer := time.NewTimer(retry)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+func (e *eventHandlerError) Error() string {
+	return e.err.Error()
+}
+
+// connect reads a single connection of the stream. io.EOF means the server closed the stream after connecting.
+func (es *eventSourceImpl) connect(ctx context.Context, handler func(event eighty.ServerSentEvent) error) (connected bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, es.url, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set(eighty.AcceptHeader, eighty.EventStreamContentType[0])
+	req.Header.Set(eighty.CacheControlHeader, "no-cache")
+	if lastEventID := es.LastEventID(); len(lastEventID) > 0 {
+		req.Header.Set(eighty.LastEventIDHeader, lastEventID)
+	}
+
+	res, err := es.cli.Do(req)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		// the server asks the client to stop reconnecting
+		return true, errEventStreamEnded
+	default:
+		body, _ := io.ReadAll(io.LimitReader(res.Body, DefaultJSONResponseLimit))
+		return false, newResponseError(res, body)
+	}
+	if mediaType, _, parseErr := mime.ParseMediaType(res.Header.Get(eighty.ContentTypeHeader)); parseErr != nil || mediaType != eighty.EventStreamContentType[0] {
+		return false, ErrNotEventStream
+	}
+
+	parser := &eventParser{source: es}
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 0, 4096), maxEventLineSize)
+	scanner.Split(splitEventLines)
+	for scanner.Scan() {
+		if err = parser.feed(scanner.Bytes(), handler); err != nil {
+			return true, &eventHandlerError{err: err}
+		}
+	}
+	if err = scanner.Err(); err == nil {
+		err = io.EOF
+	}
+	return true, err
+}
+
+// splitEventLines splits the stream into lines ending with CR, LF or CRLF, keeping the line terminator.
+func splitEventLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i+1], nil
+	} else if atEOF && len(data) > 0 {
+		// an incomplete line at the end is discarded with its event
+		return len(data), nil, nil
+	}
+	return 0, nil, nil
+}
+
+func (p *eventParser) feed(line []byte, handler func(event eighty.ServerSentEvent) error) error {
+	if len(line) == 0 {
+		return nil
+	}
+	terminator := line[len(line)-1]
+	if terminator == '\n' && p.afterCR && len(line) == 1 {
+		// the LF of a CRLF line ending
+		p.afterCR = false
+		return nil
+	}
+	p.afterCR = terminator == '\r'
+	line = line[:len(line)-1]
+
+	if len(line) == 0 {
+		return p.dispatch(handler)
+	} else if line[0] == ':' {
+		return nil
+	}
+
+	field, value := string(line), ""
+	if i := bytes.IndexByte(line, ':'); i >= 0 {
+		field, value = string(line[:i]), strings.TrimPrefix(string(line[i+1:]), " ")
+	}
+	switch field {
+	case "event":
+		p.eventType = value
+	case "data":
+		p.data.WriteString(value)
+		p.data.WriteByte('\n')
+		p.hasData = true
+	case "id":
+		if !strings.ContainsRune(value, 0) {
+			p.source.lock.Lock()
+			p.source.lastEventID = value
+			p.source.lock.Unlock()
+		}
+	case "retry":
+		if millis, err := strconv.ParseUint(value, 10, 63); err == nil {
+			p.source.lock.Lock()
+			p.source.retry = time.Duration(millis) * time.Millisecond
+			p.source.lock.Unlock()
+		}
+	}
+	return nil
+}
+
+func (p *eventParser) dispatch(handler func(event eighty.ServerSentEvent) error) error {
+	defer func() {
+		p.data.Reset()
+		p.hasData = false
+		p.eventType = ""
+	}()
+	if !p.hasData {
+		return nil
+	}
+	event := eighty.ServerSentEvent{
+		ID:    p.source.LastEventID(),
+		Event: p.eventType,
+		Data:  strings.TrimSuffix(p.data.String(), "\n"),
+	}
+	if len(event.Event) == 0 {
+		event.Event = "message"
+	}
+	p.source.lock.Lock()
+	event.Retry = p.source.retry
+	p.source.lock.Unlock()
+	return handler(event)
+}
@@ -0,0 +1,192 @@
+package client
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrCertificatePinMismatch is returned when no certificate of the server matches the pins of the host.
+	ErrCertificatePinMismatch = errors.New("server certificate does not match the pinned keys")
+)
+
+type (
+	// ClientCertificate is a client certificate for the mutual TLS loaded from PEM files.
+	// The files are checked for changes at most once per check interval and reloaded on change.
+	ClientCertificate interface {
+		// GetClientCertificate implements the tls.Config GetClientCertificate function.
+		GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error)
+		// Reload loads the PEM files again.
+		Reload() error
+	}
+
+	clientCertificateImpl struct {
+		certFile      string
+		keyFile       string
+		checkInterval time.Duration
+
+		lock        sync.Mutex
+		certificate *tls.Certificate
+		checkedAt   time.Time
+		certModTime time.Time
+		keyModTime  time.Time
+	}
+)
+
+// LoadClientCertificate returns a ClientCertificate loaded from the PEM encoded certificate and key files.
+func LoadClientCertificate(certFile, keyFile string, checkInterval time.Duration) (ClientCertificate, error) {
+	c := &clientCertificateImpl{
+		certFile:      certFile,
+		keyFile:       keyFile,
+		checkInterval: checkInterval,
+	}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *clientCertificateImpl) modTimes() (certModTime, keyModTime time.Time, err error) {
+	var stat os.FileInfo
+	if stat, err = os.Stat(c.certFile); err != nil {
+		return
+	}
+	certModTime = stat.ModTime()
+	if stat, err = os.Stat(c.keyFile); err != nil {
+		return
+	}
+	keyModTime = stat.ModTime()
+	return
+}
+
+func (c *clientCertificateImpl) Reload() error {
+	certModTime, keyModTime, err := c.modTimes()
+	if err != nil {
+		return err
+	}
+	certificate, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.certificate = &certificate
+	c.checkedAt = time.Now()
+	c.certModTime, c.keyModTime = certModTime, keyModTime
+	return nil
+}
+
+func (c *clientCertificateImpl) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	c.lock.Lock()
+	var (
+		certificate           = c.certificate
+		loadedCert, loadedKey = c.certModTime, c.keyModTime
+		stale                 = time.Since(c.checkedAt) >= c.checkInterval
+	)
+	if stale {
+		c.checkedAt = time.Now()
+	}
+	c.lock.Unlock()
+	if !stale {
+		return certificate, nil
+	}
+
+	certModTime, keyModTime, err := c.modTimes()
+	if err != nil || certModTime.Equal(loadedCert) && keyModTime.Equal(loadedKey) {
+		// keep the loaded certificate while the files are unchanged or being replaced
+		return certificate, nil
+	} else if err = c.Reload(); err != nil {
+		return certificate, nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.certificate, nil
+}
+
+// LoadCertPool returns a root CA pool with the certificates in the PEM files.
+// If includeSystem is set, the pool starts with the system roots.
+func LoadCertPool(includeSystem bool, pemFiles ...string) (pool *x509.CertPool, err error) {
+	if includeSystem {
+		if pool, err = x509.SystemCertPool(); err != nil {
+			return
+		}
+	} else {
+		pool = x509.NewCertPool()
+	}
+	for _, pemFile := range pemFiles {
+		var data []byte
+		if data, err = os.ReadFile(pemFile); err != nil {
+			return nil, err
+		} else if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.New("no certificate found in " + pemFile)
+		}
+	}
+	return
+}
+
+// SPKIPin returns the base64 encoded SHA-256 digest of the certificate public key,
+// which is the pin format of NewTLSConfig.
+func SPKIPin(certificate *x509.Certificate) string {
+	sum := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// NewTLSConfig returns a tls.Config for the client.
+// minVersion is the minimum TLS version, tls.VersionTLS12 if zero.
+// rootCAs replaces the system roots if not nil, clientCertificate enables the mutual TLS if not nil.
+// pins maps host names to the SPKIPin values one of the server certificates must match,
+// hosts without pins are verified by the roots only.
+func NewTLSConfig(minVersion uint16, rootCAs *x509.CertPool, clientCertificate ClientCertificate, pins map[string][]string) *tls.Config {
+	if minVersion == 0 {
+		minVersion = tls.VersionTLS12
+	}
+	config := &tls.Config{
+		MinVersion: minVersion,
+		RootCAs:    rootCAs,
+	}
+	if clientCertificate != nil {
+		config.GetClientCertificate = clientCertificate.GetClientCertificate
+	}
+	if len(pins) > 0 {
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			hostPins, ok := pins[state.ServerName]
+			if len(state.ServerName) == 0 && len(state.PeerCertificates) > 0 {
+				// IP addresses are not sent as the server name, enforce the pins of every host the certificate is valid for
+				for host, pinned := range pins {
+					if state.PeerCertificates[0].VerifyHostname(host) == nil {
+						hostPins, ok = append(hostPins, pinned...), true
+					}
+				}
+			}
+			if !ok {
+				return nil
+			}
+			for _, certificate := range state.PeerCertificates {
+				pin := SPKIPin(certificate)
+				for _, hostPin := range hostPins {
+					if subtle.ConstantTimeCompare([]byte(pin), []byte(hostPin)) == 1 {
+						return nil
+					}
+				}
+			}
+			return ErrCertificatePinMismatch
+		}
+	}
+	return config
+}
+
+// WithTLSConfig sets the tls.Config used for https connections.
+func WithTLSConfig(config *tls.Config) DialOption {
+	return func(d *dialer) {
+		d.tlsConfig = config
+	}
+}
@@ -0,0 +1,156 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeClientCertificate writes a self-signed client certificate with the common name to the PEM files.
+func writeClientCertificate(t *testing.T, certFile, keyFile, commonName string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	} else if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTLSServer returns a TLS server that requires a client certificate and answers with its common name.
+func newTLSServer() *httptest.Server {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	// the rejected handshakes are expected
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	return srv
+}
+
+func getTLS(t *testing.T, config *tls.Config, target string) (string, error) {
+	t.Helper()
+	rt := NewRoundTripper(0, time.Second, time.Second, time.Second, 1, "", WithTLSConfig(config))
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := rt.RoundTrip(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	return string(body), err
+}
+
+func TestClientCertificateReload(t *testing.T) {
+	srv := newTLSServer()
+	defer srv.Close()
+
+	var (
+		dir      = t.TempDir()
+		certFile = filepath.Join(dir, "client.crt")
+		keyFile  = filepath.Join(dir, "client.key")
+		roots    = x509.NewCertPool()
+	)
+	roots.AddCert(srv.Certificate())
+	writeClientCertificate(t, certFile, keyFile, "first")
+	certificate, err := LoadClientCertificate(certFile, keyFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := NewTLSConfig(0, roots, certificate, nil)
+
+	if name, err := getTLS(t, config, srv.URL); err != nil {
+		t.Fatal(err)
+	} else if name != "first" {
+		t.Fatalf("server saw the certificate %q, want %q", name, "first")
+	}
+
+	writeClientCertificate(t, certFile, keyFile, "second")
+	// the modification times may not change within the file system resolution
+	later := time.Now().Add(time.Minute)
+	for _, file := range []string{certFile, keyFile} {
+		if err = os.Chtimes(file, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if name, err := getTLS(t, config, srv.URL); err != nil {
+		t.Fatal(err)
+	} else if name != "second" {
+		t.Fatalf("server saw the certificate %q after the reload, want %q", name, "second")
+	}
+}
+
+func TestTLSConfigPins(t *testing.T) {
+	srv := newTLSServer()
+	defer srv.Close()
+
+	var (
+		dir      = t.TempDir()
+		certFile = filepath.Join(dir, "client.crt")
+		keyFile  = filepath.Join(dir, "client.key")
+		roots    = x509.NewCertPool()
+	)
+	roots.AddCert(srv.Certificate())
+	writeClientCertificate(t, certFile, keyFile, "pinned")
+	certificate, err := LoadClientCertificate(certFile, keyFile, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		pins    map[string][]string
+		wantErr error
+	}{
+		{name: "matching pin", pins: map[string][]string{"127.0.0.1": {"bm90IHRoZSBwaW4=", SPKIPin(srv.Certificate())}}},
+		{name: "mismatching pin", pins: map[string][]string{"127.0.0.1": {"bm90IHRoZSBwaW4="}}, wantErr: ErrCertificatePinMismatch},
+		{name: "other host pinned", pins: map[string][]string{"api.example.org": {"bm90IHRoZSBwaW4="}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := getTLS(t, NewTLSConfig(0, roots, certificate, test.pins), srv.URL)
+			if test.wantErr == nil && err != nil {
+				t.Fatal(err)
+			} else if test.wantErr != nil && !errors.Is(err, test.wantErr) {
+				t.Fatalf("got %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
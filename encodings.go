@@ -21,6 +21,8 @@ const (
 	Server               = "Server"
 	VaryHeader           = "Vary"
 	ForwardedForIPHeader = "X-Forwarded-For"
+	LastEventIDHeader    = "Last-Event-ID"
+	AcceptHeader         = "Accept"
 )
 
 // Collection of predefined response header names.
@@ -32,6 +34,10 @@ const (
 	XssProtectionHeader     = "X-XSS-Protection"
 	XCsrfToken              = "X-CSRF-Token"
 	XForwardedProto         = "X-Forwarded-Proto"
+	XForwardedHost          = "X-Forwarded-Host"
+	AllowHeader             = "Allow"
+	DeprecationHeader       = "Deprecation"
+	SunsetHeader            = "Sunset"
 )
 
 // Collection of predefined cache header values.
@@ -50,6 +56,7 @@ var (
 	UrlencodeContentType     = []string{"application/x-www-form-urlencoded"}
 	JsonContentUTF8Type      = []string{"application/json; charset=utf-8"}
 	JsonContentType          = []string{"application/json"}
+	EventStreamContentType   = []string{"text/event-stream"}
 )
 
 // Collection of predefined CSRF header values.
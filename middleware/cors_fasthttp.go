@@ -0,0 +1,214 @@
+package middleware
+
+import (
+	"github.com/spi-ca/eighty"
+	"github.com/spi-ca/eighty/routing"
+	"github.com/spi-ca/misc/strutil"
+	"github.com/valyala/fasthttp"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Collection of CORS header names.
+const (
+	AccessControlAllowOriginHeader      = "Access-Control-Allow-Origin"
+	AccessControlAllowCredentialsHeader = "Access-Control-Allow-Credentials"
+	AccessControlAllowMethodsHeader     = "Access-Control-Allow-Methods"
+	AccessControlAllowHeadersHeader     = "Access-Control-Allow-Headers"
+	AccessControlExposeHeadersHeader    = "Access-Control-Expose-Headers"
+	AccessControlMaxAgeHeader           = "Access-Control-Max-Age"
+	AccessControlRequestMethodHeader    = "Access-Control-Request-Method"
+	AccessControlRequestHeadersHeader   = "Access-Control-Request-Headers"
+
+	// the key of the policy that answers the preflight request, each CORS middleware replaces the outer one
+	corsPolicyContextKey = "corsPolicy"
+)
+
+type (
+	// CORSPolicy is the cross-origin resource sharing policy of a routing group.
+	CORSPolicy struct {
+		// AllowOrigins lists the exact origins, "*" for any origin,
+		// or wildcard subdomains such as "https://*.example.com".
+		AllowOrigins []string
+		// AllowOriginPatterns lists the regular expressions the whole origin may match.
+		AllowOriginPatterns []*regexp.Regexp
+		// AllowOriginFunc decides the origins that the lists do not allow, if not nil.
+		AllowOriginFunc func(origin string) bool
+		// AllowCredentials allows cookies and authorization headers, the origin is echoed instead of "*".
+		AllowCredentials bool
+		// AllowHeaders lists the request headers allowed on preflight, the requested headers are reflected if empty.
+		AllowHeaders []string
+		// ExposeHeaders lists the response headers readable by the script.
+		ExposeHeaders []string
+		// MaxAge is how long the preflight result can be cached, not sent if zero.
+		MaxAge time.Duration
+	}
+
+	corsMiddleware struct {
+		policy      CORSPolicy
+		anyOrigin   bool
+		exact       map[string]bool
+		wildcards   [][2]string
+		maxAge      string
+		exposed     string
+		allowedHdrs string
+	}
+)
+
+// CORSFunc returns a routing.Middleware that applies the CORS policy, the innermost policy of a route wins.
+// Preflight requests are passed to the OPTIONS route of the path like any OPTIONS request, and are answered
+// with the methods of its Allow header. Since they carry no credentials, the middlewares that follow
+// must let them through, such as by registering the authentication on the routes rather than on the group.
+func CORSFunc(policy CORSPolicy) routing.Middleware {
+	m := &corsMiddleware{
+		policy:      policy,
+		exact:       make(map[string]bool),
+		exposed:     strings.Join(policy.ExposeHeaders, ", "),
+		allowedHdrs: strings.Join(policy.AllowHeaders, ", "),
+	}
+	for _, origin := range policy.AllowOrigins {
+		if origin == "*" {
+			m.anyOrigin = true
+		} else if prefix, suffix, ok := strings.Cut(origin, "*"); ok {
+			m.wildcards = append(m.wildcards, [2]string{strings.ToLower(prefix), strings.ToLower(suffix)})
+		} else {
+			m.exact[strings.ToLower(origin)] = true
+		}
+	}
+	if policy.MaxAge > 0 {
+		m.maxAge = strconv.FormatInt(int64(policy.MaxAge/time.Second), 10)
+	}
+	return m.Handle
+}
+
+func (m *corsMiddleware) allowed(origin string) bool {
+	lowered := strings.ToLower(origin)
+	if m.anyOrigin || m.exact[lowered] {
+		return true
+	}
+	for _, wildcard := range m.wildcards {
+		if len(lowered) > len(wildcard[0])+len(wildcard[1]) && strings.HasPrefix(lowered, wildcard[0]) && strings.HasSuffix(lowered, wildcard[1]) &&
+			!strings.ContainsAny(lowered[len(wildcard[0]):len(lowered)-len(wildcard[1])], "/:@") {
+			return true
+		}
+	}
+	for _, pattern := range m.policy.AllowOriginPatterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+	return m.policy.AllowOriginFunc != nil && m.policy.AllowOriginFunc(origin)
+}
+
+// addVary merges the values into the Vary header without duplicates.
+func addVary(header *fasthttp.ResponseHeader, values ...string) {
+	current := strutil.B2S(header.Peek(eighty.VaryHeader))
+	merged := current
+	for _, value := range values {
+		found := false
+		for _, existing := range strings.Split(current, ",") {
+			if existing = strings.TrimSpace(existing); strings.EqualFold(existing, value) || existing == "*" {
+				found = true
+				break
+			}
+		}
+		if !found {
+			if len(merged) > 0 {
+				merged += ", "
+			}
+			merged += value
+		}
+	}
+	if merged != current {
+		header.Set(eighty.VaryHeader, merged)
+	}
+}
+
+func (m *corsMiddleware) resetHeaders(header *fasthttp.ResponseHeader) {
+	for _, name := range []string{
+		AccessControlAllowOriginHeader, AccessControlAllowCredentialsHeader, AccessControlAllowMethodsHeader,
+		AccessControlAllowHeadersHeader, AccessControlExposeHeadersHeader, AccessControlMaxAgeHeader,
+	} {
+		header.Del(name)
+	}
+}
+
+func (m *corsMiddleware) setOrigin(header *fasthttp.ResponseHeader, origin string) {
+	if m.anyOrigin && !m.policy.AllowCredentials {
+		header.Set(AccessControlAllowOriginHeader, "*")
+	} else {
+		header.Set(AccessControlAllowOriginHeader, origin)
+	}
+	if m.policy.AllowCredentials {
+		header.Set(AccessControlAllowCredentialsHeader, "true")
+	}
+}
+
+func (m *corsMiddleware) Handle(next routing.Router) routing.Router {
+	return func(ctx *fasthttp.RequestCtx) {
+		origin := string(ctx.Request.Header.Peek(eighty.OriginHeader))
+		requestMethod := string(ctx.Request.Header.Peek(AccessControlRequestMethodHeader))
+		if len(origin) > 0 && len(requestMethod) > 0 && ctx.IsOptions() {
+			ctx.SetUserValue(corsPolicyContextKey, m)
+			next(ctx)
+			if ctx.UserValue(corsPolicyContextKey) == m {
+				m.preflight(ctx, origin, requestMethod)
+			}
+			return
+		}
+
+		header := &ctx.Response.Header
+		if len(origin) > 0 {
+			// an inner group policy replaces the outer one
+			m.resetHeaders(header)
+			if m.allowed(origin) {
+				m.setOrigin(header, origin)
+				if len(m.exposed) > 0 {
+					header.Set(AccessControlExposeHeadersHeader, m.exposed)
+				}
+			}
+		}
+		if !m.anyOrigin || m.policy.AllowCredentials {
+			addVary(header, eighty.OriginHeader)
+		}
+		next(ctx)
+		if !m.anyOrigin || m.policy.AllowCredentials {
+			addVary(header, eighty.OriginHeader)
+		}
+	}
+}
+
+// preflight completes the answer of the OPTIONS route to the preflight request with the policy.
+func (m *corsMiddleware) preflight(ctx *fasthttp.RequestCtx, origin, requestMethod string) {
+	header := &ctx.Response.Header
+	addVary(header, eighty.OriginHeader, AccessControlRequestMethodHeader, AccessControlRequestHeadersHeader)
+	m.resetHeaders(header)
+	// the path may have no OPTIONS route, or a middleware may have rejected the request
+	if status := ctx.Response.StatusCode(); status < fasthttp.StatusOK || status > fasthttp.StatusIMUsed || !m.allowed(origin) {
+		return
+	}
+	allowedMethods := string(header.Peek(eighty.AllowHeader))
+	methodAllowed := false
+	for _, method := range strings.Split(allowedMethods, ",") {
+		if strings.TrimSpace(method) == requestMethod {
+			methodAllowed = true
+			break
+		}
+	}
+	if !methodAllowed {
+		return
+	}
+
+	m.setOrigin(header, origin)
+	header.Set(AccessControlAllowMethodsHeader, allowedMethods)
+	if len(m.allowedHdrs) > 0 {
+		header.Set(AccessControlAllowHeadersHeader, m.allowedHdrs)
+	} else if requested := ctx.Request.Header.Peek(AccessControlRequestHeadersHeader); len(requested) > 0 {
+		header.SetBytesV(AccessControlAllowHeadersHeader, requested)
+	}
+	if len(m.maxAge) > 0 {
+		header.Set(AccessControlMaxAgeHeader, m.maxAge)
+	}
+}
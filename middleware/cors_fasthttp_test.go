@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/spi-ca/eighty"
+	"github.com/spi-ca/eighty/routing"
+	"github.com/valyala/fasthttp"
+)
+
+func serve(handler routing.Router, method, uri string, headers ...string) *fasthttp.RequestCtx {
+	var ctx fasthttp.RequestCtx
+	ctx.Request.Header.SetMethod(method)
+	ctx.Request.SetRequestURI(uri)
+	for i := 0; i+1 < len(headers); i += 2 {
+		ctx.Request.Header.Set(headers[i], headers[i+1])
+	}
+	handler(&ctx)
+	return &ctx
+}
+
+func TestCORSPreflight(t *testing.T) {
+	var innerRuns int
+	registry := routing.NewRouterContext("", routing.NewUrlFor()).BuildRouter(nil)
+	api := registry.Wrap("api", "/api", CORSFunc(CORSPolicy{AllowOrigins: []string{"https://outer.example.org"}}))
+	admin := api.Wrap("admin", "/admin",
+		CORSFunc(CORSPolicy{AllowOrigins: []string{"https://*.example.com"}, AllowCredentials: true}),
+		func(next routing.Router) routing.Router {
+			return func(ctx *fasthttp.RequestCtx) {
+				innerRuns++
+				ctx.Response.Header.Set("X-Inner", "ran")
+				next(ctx)
+			}
+		},
+	)
+	handler := func(ctx *fasthttp.RequestCtx) { ctx.SetBodyString("ok") }
+	api.Register("items", "/items", nil, handler, nil, fasthttp.MethodGet, fasthttp.MethodPost)
+	admin.Register("users", "/users", nil, handler, nil, fasthttp.MethodGet, fasthttp.MethodDelete)
+
+	tests := []struct {
+		name        string
+		uri         string
+		origin      string
+		method      string
+		wantOrigin  string
+		wantMethods string
+	}{
+		{name: "outer policy", uri: "/api/items", origin: "https://outer.example.org", method: fasthttp.MethodPost,
+			wantOrigin: "https://outer.example.org", wantMethods: "GET, OPTIONS, POST"},
+		{name: "outer policy rejects origin", uri: "/api/items", origin: "https://evil.example.net", method: fasthttp.MethodPost},
+		{name: "unregistered method", uri: "/api/items", origin: "https://outer.example.org", method: fasthttp.MethodDelete},
+		{name: "inner policy wins", uri: "/api/admin/users", origin: "https://app.example.com", method: fasthttp.MethodDelete,
+			wantOrigin: "https://app.example.com", wantMethods: "DELETE, GET, OPTIONS"},
+		{name: "inner policy replaces outer origins", uri: "/api/admin/users", origin: "https://outer.example.org", method: fasthttp.MethodDelete},
+		{name: "inner policy rejects origin", uri: "/api/admin/users", origin: "https://example.org", method: fasthttp.MethodGet},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := serve(registry.Handler, fasthttp.MethodOptions, test.uri,
+				eighty.OriginHeader, test.origin, AccessControlRequestMethodHeader, test.method)
+			header := &ctx.Response.Header
+			if status := ctx.Response.StatusCode(); status != fasthttp.StatusNoContent {
+				t.Errorf("status %d, want %d", status, fasthttp.StatusNoContent)
+			}
+			if origin := string(header.Peek(AccessControlAllowOriginHeader)); origin != test.wantOrigin {
+				t.Errorf("allowed origin %q, want %q", origin, test.wantOrigin)
+			}
+			if methods := string(header.Peek(AccessControlAllowMethodsHeader)); methods != test.wantMethods {
+				t.Errorf("allowed methods %q, want %q", methods, test.wantMethods)
+			}
+			if vary := string(header.Peek(eighty.VaryHeader)); vary != "Origin, Access-Control-Request-Method, Access-Control-Request-Headers" {
+				t.Errorf("Vary %q", vary)
+			}
+		})
+	}
+
+	// the preflight passes the middlewares of the route like any OPTIONS request
+	innerRuns = 0
+	ctx := serve(registry.Handler, fasthttp.MethodOptions, "/api/admin/users",
+		eighty.OriginHeader, "https://app.example.com", AccessControlRequestMethodHeader, fasthttp.MethodGet)
+	if innerRuns != 1 || string(ctx.Response.Header.Peek("X-Inner")) != "ran" {
+		t.Errorf("the inner middleware ran %d times on the preflight, want once", innerRuns)
+	} else if credentials := string(ctx.Response.Header.Peek(AccessControlAllowCredentialsHeader)); credentials != "true" {
+		t.Errorf("allowed credentials %q, want %q", credentials, "true")
+	}
+}
+
+func TestCORSPreflightRejectedByMiddleware(t *testing.T) {
+	registry := routing.NewRouterContext("", routing.NewUrlFor()).BuildRouter(nil)
+	api := registry.Wrap("api", "/api",
+		CORSFunc(CORSPolicy{AllowOrigins: []string{"*"}}),
+		func(next routing.Router) routing.Router {
+			return func(ctx *fasthttp.RequestCtx) {
+				ctx.SetStatusCode(fasthttp.StatusUnauthorized)
+			}
+		},
+	)
+	api.Register("items", "/items", nil, func(ctx *fasthttp.RequestCtx) {}, nil, fasthttp.MethodGet)
+
+	ctx := serve(registry.Handler, fasthttp.MethodOptions, "/api/items",
+		eighty.OriginHeader, "https://app.example.com", AccessControlRequestMethodHeader, fasthttp.MethodGet)
+	if status := ctx.Response.StatusCode(); status != fasthttp.StatusUnauthorized {
+		t.Errorf("status %d, want %d", status, fasthttp.StatusUnauthorized)
+	} else if origin := ctx.Response.Header.Peek(AccessControlAllowOriginHeader); len(origin) > 0 {
+		t.Errorf("a rejected preflight allowed the origin %q", origin)
+	}
+}
+
+func TestCORSActualRequest(t *testing.T) {
+	registry := routing.NewRouterContext("", routing.NewUrlFor()).BuildRouter(nil)
+	api := registry.Wrap("api", "/api", CORSFunc(CORSPolicy{
+		AllowOrigins:  []string{"https://app.example.com"},
+		ExposeHeaders: []string{"X-Total", "X-Page"},
+	}))
+	api.Register("items", "/items", nil, func(ctx *fasthttp.RequestCtx) { ctx.SetBodyString("items") }, nil, fasthttp.MethodGet)
+
+	ctx := serve(registry.Handler, fasthttp.MethodGet, "/api/items", eighty.OriginHeader, "https://app.example.com")
+	header := &ctx.Response.Header
+	if origin := string(header.Peek(AccessControlAllowOriginHeader)); origin != "https://app.example.com" {
+		t.Errorf("allowed origin %q", origin)
+	} else if exposed := string(header.Peek(AccessControlExposeHeadersHeader)); exposed != "X-Total, X-Page" {
+		t.Errorf("exposed headers %q", exposed)
+	} else if vary := string(header.Peek(eighty.VaryHeader)); vary != "Origin" {
+		t.Errorf("Vary %q, want %q", vary, "Origin")
+	} else if string(ctx.Response.Body()) != "items" {
+		t.Errorf("body %q", ctx.Response.Body())
+	}
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"github.com/spi-ca/eighty"
+	"github.com/spi-ca/eighty/routing"
+	"github.com/spi-ca/misc/strutil"
+	"github.com/valyala/fasthttp"
+	"net/http"
+	"time"
+)
+
+const (
+	// the name of HMAC key id
+	signatureKeyIDContextKey = "signatureKeyID"
+)
+
+type signatureMiddleware struct {
+	keyResolver     func(keyID string) (secret []byte, ok bool)
+	maxSkew         time.Duration
+	requiredHeaders []string
+}
+
+// SignatureKeyID returns the verified HMAC key id in the current request context.
+// If the request was not verified, zero-value returned.
+func SignatureKeyID(ctx *fasthttp.RequestCtx) (keyID string) {
+	keyID, _ = ctx.UserValue(signatureKeyIDContextKey).(string)
+	return
+}
+
+func (m *signatureMiddleware) covers(signedHeaders []string) bool {
+	for _, required := range m.requiredHeaders {
+		found := false
+		for _, name := range signedHeaders {
+			if found = name == required; found {
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *signatureMiddleware) verify(ctx *fasthttp.RequestCtx) (keyID string, ok bool) {
+	keyID, signedHeaders, signature, err := eighty.ParseSignature(strutil.B2S(ctx.Request.Header.Peek(eighty.SignatureHeader)))
+	if err != nil || !m.covers(signedHeaders) {
+		return
+	}
+	secret, found := m.keyResolver(keyID)
+	if !found {
+		return
+	}
+
+	signedAt, err := http.ParseTime(strutil.B2S(ctx.Request.Header.Peek(eighty.SignatureDateHeader)))
+	if err != nil {
+		return
+	} else if skew := time.Since(signedAt); skew > m.maxSkew || skew < -m.maxSkew {
+		return
+	}
+	if !hmac.Equal([]byte(eighty.BodyDigest(ctx.PostBody())), ctx.Request.Header.Peek(eighty.DigestHeader)) {
+		return
+	}
+
+	base := eighty.SignatureBase(strutil.B2S(ctx.Method()), strutil.B2S(ctx.RequestURI()), signedHeaders, func(name string) string {
+		return string(ctx.Request.Header.Peek(name))
+	})
+	ok = hmac.Equal([]byte(eighty.SignHMAC(secret, base)), []byte(signature))
+	return
+}
+
+func (m *signatureMiddleware) Handle(h routing.Router) routing.Router {
+	return func(ctx *fasthttp.RequestCtx) {
+		keyID, ok := m.verify(ctx)
+		if !ok {
+			panic(eighty.HandledErrorUnauthorized)
+		}
+		ctx.SetUserValue(signatureKeyIDContextKey, keyID)
+		h(ctx)
+	}
+}
+
+// HMACVerifyFunc returns a routing.Middleware that verifies requests signed by client.NewHMACSigningTransport.
+// keyResolver looks up the shared secret of the key id, maxSkew bounds the signing date drift
+// and requiredHeaders lists the extra headers that must be covered by the signature.
+func HMACVerifyFunc(keyResolver func(keyID string) (secret []byte, ok bool), maxSkew time.Duration, requiredHeaders ...string) routing.Middleware {
+	return (&signatureMiddleware{
+		keyResolver:     keyResolver,
+		maxSkew:         maxSkew,
+		requiredHeaders: eighty.SignatureHeaders(requiredHeaders...),
+	}).Handle
+}
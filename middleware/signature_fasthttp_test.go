@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spi-ca/eighty/client"
+	"github.com/valyala/fasthttp"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// signedRequest signs the request with the client transport and returns it as a fasthttp request context.
+func signedRequest(t *testing.T, req *http.Request, keyID string, secret []byte, extraHeaders ...string) *fasthttp.RequestCtx {
+	t.Helper()
+	var ctx fasthttp.RequestCtx
+	transport := client.NewHMACSigningTransport(roundTripFunc(func(signed *http.Request) (*http.Response, error) {
+		ctx.Request.Header.SetMethod(signed.Method)
+		ctx.Request.SetRequestURI(signed.URL.RequestURI())
+		ctx.Request.Header.SetHost(signed.URL.Host)
+		if len(signed.Host) > 0 {
+			ctx.Request.Header.SetHost(signed.Host)
+		}
+		for name, values := range signed.Header {
+			for _, value := range values {
+				ctx.Request.Header.Add(name, value)
+			}
+		}
+		if signed.Body != nil {
+			body, err := io.ReadAll(signed.Body)
+			if err != nil {
+				return nil, err
+			}
+			ctx.Request.SetBody(body)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}), keyID, secret, extraHeaders...)
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	return &ctx
+}
+
+func TestHMACSignAndVerify(t *testing.T) {
+	secrets := map[string][]byte{"key, 1": []byte("secret")}
+	verify := HMACVerifyFunc(func(keyID string) ([]byte, bool) {
+		secret, ok := secrets[keyID]
+		return secret, ok
+	}, time.Minute, "host")(func(ctx *fasthttp.RequestCtx) {
+		ctx.SetBodyString(SignatureKeyID(ctx))
+	})
+
+	newRequest := func(host string) *http.Request {
+		req, err := http.NewRequest(http.MethodPost, "http://10.0.0.1:8080/orders?page=2", strings.NewReader(`{"id":1}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Host = host
+		return req
+	}
+	tests := []struct {
+		name   string
+		ctx    func() *fasthttp.RequestCtx
+		wantOK bool
+	}{
+		{name: "url host", ctx: func() *fasthttp.RequestCtx {
+			return signedRequest(t, newRequest(""), "key, 1", secrets["key, 1"], "host")
+		}, wantOK: true},
+		{name: "host header override", ctx: func() *fasthttp.RequestCtx {
+			return signedRequest(t, newRequest("api.example.com"), "key, 1", secrets["key, 1"], "host")
+		}, wantOK: true},
+		{name: "unknown key", ctx: func() *fasthttp.RequestCtx {
+			return signedRequest(t, newRequest(""), "key, 2", secrets["key, 1"], "host")
+		}},
+		{name: "host not signed", ctx: func() *fasthttp.RequestCtx {
+			return signedRequest(t, newRequest(""), "key, 1", secrets["key, 1"])
+		}},
+		{name: "tampered body", ctx: func() *fasthttp.RequestCtx {
+			ctx := signedRequest(t, newRequest(""), "key, 1", secrets["key, 1"], "host")
+			ctx.Request.SetBodyString(`{"id":2}`)
+			return ctx
+		}},
+		{name: "rerouted host", ctx: func() *fasthttp.RequestCtx {
+			ctx := signedRequest(t, newRequest("api.example.com"), "key, 1", secrets["key, 1"], "host")
+			ctx.Request.Header.SetHost("other.example.com")
+			return ctx
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := test.ctx()
+			rejected := func() (rejected bool) {
+				defer func() { rejected = recover() != nil }()
+				verify(ctx)
+				return
+			}()
+			if rejected == test.wantOK {
+				t.Fatalf("accepted %t, want %t", !rejected, test.wantOK)
+			} else if test.wantOK && string(ctx.Response.Body()) != "key, 1" {
+				t.Errorf("key id %q, want %q", ctx.Response.Body(), "key, 1")
+			}
+		})
+	}
+}
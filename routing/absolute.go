@@ -0,0 +1,113 @@
+package routing
+
+import (
+	"errors"
+	"github.com/spi-ca/eighty"
+	"github.com/spi-ca/misc/strutil"
+	"github.com/valyala/fasthttp"
+	"net/url"
+	"strings"
+)
+
+// WithCanonicalOrigin sets the origin of AbsoluteUrlResolver, such as "https://example.com".
+func WithCanonicalOrigin(origin string) RouterContextOption {
+	return func(ctx *routerContextImpl) {
+		ctx.canonicalOrigin = strings.TrimSuffix(origin, "/")
+	}
+}
+
+// WithTrustedProxy makes RequestUrlResolver trust the X-Forwarded-Proto and X-Forwarded-Host headers.
+// Use it only behind a proxy that overwrites them.
+func WithTrustedProxy() RouterContextOption {
+	return func(ctx *routerContextImpl) {
+		ctx.trustForwarded = true
+	}
+}
+
+// WithAllowedHosts restricts the request hosts that RequestUrlResolver accepts,
+// so that a forged Host header cannot leak into generated links.
+func WithAllowedHosts(hosts ...string) RouterContextOption {
+	return func(ctx *routerContextImpl) {
+		ctx.allowedHosts = hosts
+	}
+}
+
+// WithPrefixedRoutes prepends the URL prefix to every registered route and therefore to every reversed URL,
+// including the absolute ones.
+// It is opt-in, as the URL prefix was only ever reported by UrlPrefix: a server behind a proxy that strips
+// the prefix serves its routes without it, and prefixing them by default would move every existing route.
+func WithPrefixedRoutes() RouterContextOption {
+	return func(ctx *routerContextImpl) {
+		ctx.prefixRoutes = true
+	}
+}
+
+// absoluteResolver returns a UrlResolver that prepends the origin to the reversed paths,
+// the host-qualified URLs of the host groups only take its scheme.
+func absoluteResolver(resolver UrlResolver, origin string) UrlResolver {
+	parsed, err := url.Parse(origin)
+	if err == nil && (len(parsed.Scheme) == 0 || len(parsed.Host) == 0 || len(strings.Trim(parsed.Path, "/")) > 0) {
+		err = errors.New("invalid origin: " + origin)
+	}
+	return reverseRouteResolver(func(urlName string, params []string) (string, error) {
+		if err != nil {
+			return "", err
+		}
+		res, reverseErr := resolver.ReverseWithParams(urlName, params)
+		if reverseErr != nil {
+			return "", reverseErr
+		}
+		if strings.HasPrefix(res, "//") {
+			return parsed.Scheme + ":" + res, nil
+		}
+		return parsed.Scheme + "://" + parsed.Host + res, nil
+	})
+}
+
+func (ctx *routerContextImpl) CanonicalOrigin() string { return ctx.canonicalOrigin }
+
+func (ctx *routerContextImpl) AbsoluteUrlResolver() UrlResolver {
+	if len(ctx.canonicalOrigin) == 0 {
+		return reverseRouteResolver(func(string, []string) (string, error) {
+			return "", errors.New("canonical origin is not configured")
+		})
+	}
+	return absoluteResolver(ctx.UrlResolver(), ctx.canonicalOrigin)
+}
+
+func (ctx *routerContextImpl) RequestUrlResolver(r *fasthttp.RequestCtx) (UrlResolver, error) {
+	scheme, host := "http", strings.ToLower(strutil.B2S(r.Host()))
+	if r.IsTLS() {
+		scheme = "https"
+	}
+	if ctx.trustForwarded {
+		if proto := firstHeaderValue(r.Request.Header.Peek(eighty.XForwardedProto)); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwardedHost := firstHeaderValue(r.Request.Header.Peek(eighty.XForwardedHost)); len(forwardedHost) > 0 {
+			host = forwardedHost
+		}
+	}
+
+	if len(host) == 0 || strings.ContainsAny(host, "/?#@ ") {
+		return nil, errors.New("invalid request host: " + host)
+	} else if len(ctx.allowedHosts) > 0 {
+		allowed := false
+		for _, allowedHost := range ctx.allowedHosts {
+			if strings.EqualFold(allowedHost, host) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, errors.New("request host is not allowed: " + host)
+		}
+	}
+	return absoluteResolver(ctx.UrlResolver(), scheme+"://"+host), nil
+}
+
+// firstHeaderValue returns the first of the comma separated header values in lower case.
+func firstHeaderValue(value []byte) string {
+	first, _, _ := strings.Cut(strutil.B2S(value), ",")
+	return strings.ToLower(strings.TrimSpace(first))
+}
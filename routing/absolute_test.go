@@ -0,0 +1,38 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestPrefixedRoutes(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []RouterContextOption
+		wantPath string
+	}{
+		{name: "reported only", wantPath: "/api/users"},
+		{name: "prefixed", options: []RouterContextOption{WithPrefixedRoutes()}, wantPath: "/v1/api/users"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := append([]RouterContextOption{WithCanonicalOrigin("https://example.com/")}, test.options...)
+			routerCtx := NewRouterContext("/v1", NewUrlFor(), options...)
+			registry := routerCtx.BuildRouter(nil)
+			registry.Wrap("api", "/api").Register("users", "/users", nil, writeBody("users"), nil, fasthttp.MethodGet)
+
+			if prefix := routerCtx.UrlPrefix(); prefix != "/v1" {
+				t.Errorf("prefix %q", prefix)
+			}
+			if url := routerCtx.UrlResolver().MustReverse("api.users"); url != test.wantPath {
+				t.Errorf("reversed %q, want %q", url, test.wantPath)
+			} else if url = routerCtx.AbsoluteUrlResolver().MustReverse("api.users"); url != "https://example.com"+test.wantPath {
+				t.Errorf("reversed the absolute URL %q", url)
+			}
+			if body := string(serve(t, registry.Handler, fasthttp.MethodGet, test.wantPath).Response.Body()); body != "users" {
+				t.Errorf("%s is not routed, body %q", test.wantPath, body)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package routing
 
 import (
 	"github.com/fasthttp/router"
+	"github.com/valyala/fasthttp"
 	"net/http"
 )
 
@@ -13,6 +14,12 @@ type (
 		urlFor() UrlFor
 		// UrlPrefix returns the URL prefix that is added across the entire routing group.
 		UrlPrefix() string
+		// CanonicalOrigin returns the configured origin of the absolute URLs, such as "https://example.com".
+		CanonicalOrigin() string
+		// AbsoluteUrlResolver returns a UrlResolver that builds absolute URLs on the canonical origin.
+		AbsoluteUrlResolver() UrlResolver
+		// RequestUrlResolver returns a UrlResolver that builds absolute URLs on the trusted scheme and host of the request.
+		RequestUrlResolver(ctx *fasthttp.RequestCtx) (UrlResolver, error)
 		withRegister(
 			r *router.Router,
 			parentNames []string,
@@ -23,9 +30,16 @@ type (
 		BuildRouter(errHandleMiddleware Middleware) RouterRegistry
 	}
 
+	// RouterContextOption configures the RouterContext.
+	RouterContextOption func(*routerContextImpl)
+
 	routerContextImpl struct {
-		urlPrefix     string
-		reverseRouter UrlFor
+		urlPrefix       string
+		reverseRouter   UrlFor
+		canonicalOrigin string
+		trustForwarded  bool
+		allowedHosts    []string
+		prefixRoutes    bool
 	}
 )
 
@@ -43,29 +57,67 @@ func (ctx *routerContextImpl) withRegister(
 		r:                 r,
 		middlewares:       middlewares,
 		parentNames:       parentNames,
-		parentPaths:       parentPaths,
+		parentPaths:       ctx.prefixed(parentPaths),
+		inventory:         &routeInventory{},
+		hosts:             &hostRouting{fallback: r},
 	}
 }
-func (c *routerContextImpl) BuildRouter(errHandlemiddleware Middleware) RouterRegistry {
+
+// prefixed prepends the URL prefix to the group paths if WithPrefixedRoutes is set.
+func (ctx *routerContextImpl) prefixed(paths []string) []string {
+	if !ctx.prefixRoutes || len(ctx.urlPrefix) == 0 {
+		return paths
+	}
+	return append([]string{ctx.urlPrefix}, paths...)
+}
+
+// newRouter returns a router that renders the 404 and 405 responses through the error middleware, if given.
+// The 405 responses list the methods of the OPTIONS route of the path in the Allow header.
+func newRouter(errHandlemiddleware Middleware) *router.Router {
 	r := router.New()
 	r.RedirectTrailingSlash = true
 	r.RedirectFixedPath = true
 	r.HandleOPTIONS = false
 	r.HandleMethodNotAllowed = true
+	methodNotAllowed := func(ctx *fasthttp.RequestCtx) {
+		ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		ctx.SetBodyString(fasthttp.StatusMessage(fasthttp.StatusMethodNotAllowed))
+	}
 	if errHandlemiddleware != nil {
 		r.NotFound = JustCode(http.StatusNotFound, errHandlemiddleware)
-		r.MethodNotAllowed = JustCode(http.StatusMethodNotAllowed, errHandlemiddleware)
+		methodNotAllowed = JustCode(http.StatusMethodNotAllowed, errHandlemiddleware)
+	}
+	r.MethodNotAllowed = func(ctx *fasthttp.RequestCtx) {
+		setAllow(r, ctx)
+		methodNotAllowed(ctx)
+	}
+	return r
+}
+
+func (c *routerContextImpl) BuildRouter(errHandlemiddleware Middleware) RouterRegistry {
+	r := newRouter(errHandlemiddleware)
+	return &routerRegistryImpl{
+		routerContextImpl: c,
+		r:                 r,
+		parentPaths:       c.prefixed(nil),
+		inventory:         &routeInventory{},
+		hosts:             &hostRouting{fallback: r, errHandleMiddleware: errHandlemiddleware},
 	}
-	return &routerRegistryImpl{routerContextImpl: c, r: r}
 }
 
 // NewRouterContext returns a RouterContext.
+// The urlPrefix is only reported by UrlPrefix, unless WithPrefixedRoutes is set to route and reverse with it.
 func NewRouterContext(
 	urlPrefix string,
 	reverseRouter UrlFor,
+	options ...RouterContextOption,
 ) (ctx RouterContext) {
-	return &routerContextImpl{
+	impl := &routerContextImpl{
 		urlPrefix:     urlPrefix,
 		reverseRouter: reverseRouter,
 	}
+	for _, option := range options {
+		option(impl)
+	}
+	return impl
 }
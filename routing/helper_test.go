@@ -0,0 +1,44 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// serve runs the request through the handler and returns the finished request context.
+func serve(t *testing.T, handler Router, method, uri string, headers ...string) *fasthttp.RequestCtx {
+	t.Helper()
+	var ctx fasthttp.RequestCtx
+	ctx.Request.Header.SetMethod(method)
+	ctx.Request.SetRequestURI(uri)
+	for i := 0; i+1 < len(headers); i += 2 {
+		ctx.Request.Header.Set(headers[i], headers[i+1])
+	}
+	handler(&ctx)
+	return &ctx
+}
+
+// writeBody returns a handler that answers with the body.
+func writeBody(body string) Router {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctx.SetBodyString(body)
+	}
+}
+
+func TestApplyMiddlwareOrder(t *testing.T) {
+	var order string
+	mark := func(name string) Middleware {
+		return func(next Router) Router {
+			return func(ctx *fasthttp.RequestCtx) {
+				order += name
+				next(ctx)
+			}
+		}
+	}
+	handler := ApplyMiddlware(func(*fasthttp.RequestCtx) { order += "h" }, mark("a"), mark("b"))
+	serve(t, handler, fasthttp.MethodGet, "/")
+	if order != "abh" {
+		t.Fatalf("middlewares ran in the order %q, want %q", order, "abh")
+	}
+}
@@ -0,0 +1,187 @@
+package routing
+
+import (
+	"errors"
+	"github.com/fasthttp/router"
+	"github.com/spi-ca/misc/strutil"
+	"github.com/valyala/fasthttp"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// hostLabelPattern is the default constraint of a host parameter, a single DNS label.
+const hostLabelPattern = "[A-Za-z0-9-]+"
+
+var hostLabelMatcher = regexp.MustCompile("^(?:" + hostLabelPattern + ")$")
+
+type (
+	// hostGroup is the router of the routes registered under a host pattern.
+	hostGroup struct {
+		host     string
+		segments []routeSegment
+		matcher  *regexp.Regexp
+		params   []string
+		// captures are the submatch indexes of the params
+		captures []int
+		r        *router.Router
+	}
+
+	// hostRouting dispatches the requests to the host groups, the fallback router serves the other hosts.
+	hostRouting struct {
+		fallback            *router.Router
+		errHandleMiddleware Middleware
+		groups              []*hostGroup
+	}
+)
+
+// parseHostPattern splits a host pattern such as "{tenant}.example.com" into its segments.
+// A host parameter matches a single label unless it has its own constraint.
+// The literal segments are lowercased, the pattern matches the lowercased request host.
+func parseHostPattern(host string) ([]routeSegment, *regexp.Regexp, error) {
+	segments, err := parseRoutePattern(host)
+	if err != nil {
+		return nil, nil, err
+	}
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i, segment := range segments {
+		// named groups keep the captures apart from the groups of the constraints
+		group := "(?P<p" + strconv.Itoa(i) + ">"
+		switch {
+		case len(segment.param) == 0:
+			if strings.ContainsAny(segment.literal, "/:@?# ") {
+				return nil, nil, errors.New("invalid host pattern: " + host)
+			}
+			segments[i].literal = strings.ToLower(segment.literal)
+			expr.WriteString(regexp.QuoteMeta(segments[i].literal))
+		case segment.catchAll || segment.optional:
+			return nil, nil, errors.New("host parameters cannot be optional or catch-all: " + host)
+		case segment.matcher == nil:
+			segments[i].pattern, segments[i].matcher = hostLabelPattern, hostLabelMatcher
+			expr.WriteString(group + hostLabelPattern + ")")
+		default:
+			expr.WriteString(group + segment.pattern + ")")
+		}
+	}
+	expr.WriteString("$")
+	matcher, err := regexp.Compile(expr.String())
+	return segments, matcher, err
+}
+
+// hostPatternOf returns the host pattern of the parsed segments, with the literals lowercased.
+func hostPatternOf(segments []routeSegment) string {
+	var builder strings.Builder
+	for _, segment := range segments {
+		switch {
+		case len(segment.param) == 0:
+			builder.WriteString(segment.literal)
+		case segment.matcher == hostLabelMatcher:
+			builder.WriteString("{" + segment.param + "}")
+		default:
+			builder.WriteString("{" + segment.param + ":" + segment.pattern + "}")
+		}
+	}
+	return builder.String()
+}
+
+// buildHost substitutes the params into the host segments, the values must match the constraints of their params.
+func buildHost(segments []routeSegment, params []string) (string, error) {
+	var (
+		builder  strings.Builder
+		paramIdx int
+	)
+	for _, segment := range segments {
+		if len(segment.param) == 0 {
+			builder.WriteString(segment.literal)
+			continue
+		}
+		value := params[paramIdx]
+		paramIdx++
+		if !segment.matcher.MatchString(value) {
+			return "", errors.New("value " + value + " does not match the host param " + segment.param)
+		}
+		builder.WriteString(value)
+	}
+	return builder.String(), nil
+}
+
+// group returns the host group of the pattern, creating its router on the first use.
+func (hr *hostRouting) group(host string) *hostGroup {
+	segments, matcher, err := parseHostPattern(host)
+	if err != nil {
+		panic(err)
+	}
+	host = hostPatternOf(segments)
+	for _, group := range hr.groups {
+		if group.host == host {
+			return group
+		}
+	}
+	group := &hostGroup{
+		host:     host,
+		segments: segments,
+		matcher:  matcher,
+		params:   routeParamNames(segments),
+		r:        newRouter(hr.errHandleMiddleware),
+	}
+	for i, segment := range segments {
+		if len(segment.param) > 0 {
+			group.captures = append(group.captures, matcher.SubexpIndex("p"+strconv.Itoa(i)))
+		}
+	}
+	// exact hosts take precedence over the patterns
+	idx := len(hr.groups)
+	if len(group.params) == 0 {
+		for i, other := range hr.groups {
+			if len(other.params) > 0 {
+				idx = i
+				break
+			}
+		}
+	}
+	hr.groups = append(hr.groups[:idx], append([]*hostGroup{group}, hr.groups[idx:]...)...)
+	return group
+}
+
+// route serves the request with the router of the first matching host group, exact hosts first,
+// storing the captured host params as user values. The paths the group has no route for fall back
+// to the routes outside of the host groups.
+func (hr *hostRouting) route(ctx *fasthttp.RequestCtx) {
+	if len(hr.groups) > 0 {
+		host := strings.ToLower(strutil.B2S(ctx.Host()))
+		if idx := strings.LastIndexByte(host, ':'); idx >= 0 && !strings.Contains(host[idx:], "]") {
+			host = host[:idx]
+		}
+		for _, group := range hr.groups {
+			if matches := group.matcher.FindStringSubmatch(host); matches != nil {
+				if !group.serves(ctx) {
+					break
+				}
+				for i, param := range group.params {
+					ctx.SetUserValue(param, matches[group.captures[i]])
+				}
+				group.r.Handler(ctx)
+				return
+			}
+		}
+	}
+	hr.fallback.Handler(ctx)
+}
+
+// serves reports whether the group has a route for the request path, with any method or with a trailing slash redirect.
+// Every registered path has an OPTIONS route.
+func (group *hostGroup) serves(ctx *fasthttp.RequestCtx) bool {
+	handler, tsr := group.r.Lookup(fasthttp.MethodOptions, strutil.B2S(ctx.Request.URI().PathOriginal()), nil)
+	return handler != nil || tsr
+}
+
+func (r *routerRegistryImpl) WrapHost(name string, host string, middlewares ...Middleware) RouterRegistry {
+	if len(r.host) > 0 {
+		panic("cannot wrap the host " + host + " under the host group " + r.host)
+	}
+	child := r.Wrap(name, "", middlewares...).(*routerRegistryImpl)
+	group := r.hosts.group(host)
+	child.host, child.r = group.host, group.r
+	return child
+}
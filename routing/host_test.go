@@ -0,0 +1,72 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestHostGroups(t *testing.T) {
+	routerCtx := NewRouterContext("", NewUrlFor())
+	registry := routerCtx.BuildRouter(nil)
+	registry.Register("health", "/health", nil, writeBody("global health"), nil, fasthttp.MethodGet)
+	registry.Register("users", "/users", nil, writeBody("global users"), nil, fasthttp.MethodGet)
+
+	api := registry.WrapHost("api", "api.example.com")
+	api.Register("users", "/users", nil, writeBody("api users"), nil, fasthttp.MethodGet)
+	tenants := registry.WrapHost("tenant", "{tenant}.example.com")
+	tenants.Register("users", "/users", nil, func(ctx *fasthttp.RequestCtx) {
+		tenant, _ := ctx.UserValue("tenant").(string)
+		ctx.SetBodyString("tenant " + tenant)
+	}, nil, fasthttp.MethodGet)
+
+	tests := []struct {
+		name       string
+		method     string
+		uri        string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "exact host", method: fasthttp.MethodGet, uri: "http://api.example.com/users", wantStatus: fasthttp.StatusOK, wantBody: "api users"},
+		{name: "host with port", method: fasthttp.MethodGet, uri: "http://API.example.com:8080/users", wantStatus: fasthttp.StatusOK, wantBody: "api users"},
+		{name: "host pattern", method: fasthttp.MethodGet, uri: "http://acme.example.com/users", wantStatus: fasthttp.StatusOK, wantBody: "tenant acme"},
+		{name: "other host", method: fasthttp.MethodGet, uri: "http://example.org/users", wantStatus: fasthttp.StatusOK, wantBody: "global users"},
+		{name: "global route on exact host", method: fasthttp.MethodGet, uri: "http://api.example.com/health", wantStatus: fasthttp.StatusOK, wantBody: "global health"},
+		{name: "global route on host pattern", method: fasthttp.MethodGet, uri: "http://acme.example.com/health", wantStatus: fasthttp.StatusOK, wantBody: "global health"},
+		{name: "group method not allowed", method: fasthttp.MethodPost, uri: "http://api.example.com/users", wantStatus: fasthttp.StatusMethodNotAllowed},
+		{name: "unknown path", method: fasthttp.MethodGet, uri: "http://api.example.com/unknown", wantStatus: fasthttp.StatusNotFound},
+		{name: "nested label", method: fasthttp.MethodGet, uri: "http://a.b.example.com/users", wantStatus: fasthttp.StatusOK, wantBody: "global users"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := serve(t, registry.Handler, test.method, test.uri)
+			if status := ctx.Response.StatusCode(); status != test.wantStatus {
+				t.Errorf("status %d, want %d", status, test.wantStatus)
+			}
+			if len(test.wantBody) > 0 && string(ctx.Response.Body()) != test.wantBody {
+				t.Errorf("body %q, want %q", ctx.Response.Body(), test.wantBody)
+			}
+		})
+	}
+
+	resolver := routerCtx.UrlResolver()
+	if url, err := resolver.Reverse("tenant.users", "acme"); err != nil {
+		t.Fatal(err)
+	} else if url != "//acme.example.com/users" {
+		t.Errorf("reverse URL %q, want %q", url, "//acme.example.com/users")
+	}
+	if _, err := resolver.Reverse("tenant.users", "not a label"); err == nil {
+		t.Error("a host param out of its constraint was reversed")
+	}
+}
+
+func TestHostGroupsCannotNest(t *testing.T) {
+	registry := NewRouterContext("", NewUrlFor()).BuildRouter(nil)
+	api := registry.WrapHost("api", "api.example.com")
+	defer func() {
+		if recover() == nil {
+			t.Fatal("a nested host group did not panic")
+		}
+	}()
+	api.WrapHost("nested", "{tenant}.example.com")
+}
@@ -0,0 +1,130 @@
+package routing
+
+import (
+	"github.com/spi-ca/eighty"
+	"github.com/valyala/fasthttp"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+type (
+	// RouteInfo describes a registered route.
+	RouteInfo struct {
+		Name string `json:"name"`
+		Host string `json:"host,omitempty"`
+		Path string `json:"path"`
+		// Version is the API version of a versioned route, empty otherwise.
+		Version     string   `json:"version,omitempty"`
+		Methods     []string `json:"methods"`
+		Groups      []string `json:"groups"`
+		Middlewares []string `json:"middlewares"`
+		Handler     string   `json:"handler"`
+		// Tags are the tags attached by Tag, empty if none.
+		Tags string `json:"tags,omitempty"`
+		// Operation is the OpenAPI metadata attached by Describe, nil if none.
+		Operation *Operation `json:"operation,omitempty"`
+	}
+
+	// RouteTable is the inventory of the registered routes in registration order.
+	RouteTable []RouteInfo
+
+	// routeInventory collects the routes of the routers shared by the groups and the host groups.
+	routeInventory struct {
+		lock   sync.RWMutex
+		routes []RouteInfo
+		// options are the OPTIONS routes by host pattern and path
+		options map[string]*optionsRoute
+		// metas are the metadata of the routes by their full name
+		metas map[string]*RouteMeta
+	}
+)
+
+// funcName returns the qualified name of the function value.
+func funcName(fn any) string {
+	value := reflect.ValueOf(fn)
+	if value.Kind() != reflect.Func || value.IsNil() {
+		return ""
+	}
+	if f := runtime.FuncForPC(value.Pointer()); f != nil {
+		return strings.TrimSuffix(f.Name(), "-fm")
+	}
+	return ""
+}
+
+func (inv *routeInventory) add(route RouteInfo, meta *RouteMeta) {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	if inv.metas == nil {
+		inv.metas = make(map[string]*RouteMeta)
+	}
+	inv.metas[route.Name] = meta
+	inv.routes = append(inv.routes, route)
+}
+
+// describe attaches the operation to the route, reporting whether the route exists.
+func (inv *routeInventory) describe(name string, operation Operation) bool {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	for i := range inv.routes {
+		if inv.routes[i].Name == name {
+			inv.routes[i].Operation = &operation
+			return true
+		}
+	}
+	return false
+}
+
+func (inv *routeInventory) snapshot() RouteTable {
+	inv.lock.RLock()
+	defer inv.lock.RUnlock()
+	return append(RouteTable(nil), inv.routes...)
+}
+
+// Filter returns the routes that keep accepts, in registration order.
+func (t RouteTable) Filter(keep func(route RouteInfo) bool) RouteTable {
+	var filtered RouteTable
+	for _, route := range t {
+		if keep(route) {
+			filtered = append(filtered, route)
+		}
+	}
+	return filtered
+}
+
+// String renders the table as aligned text columns.
+func (t RouteTable) String() string {
+	var builder strings.Builder
+	w := tabwriter.NewWriter(&builder, 0, 4, 2, ' ', 0)
+	_, _ = w.Write([]byte("NAME\tHOST\tPATH\tMETHODS\tGROUPS\tMIDDLEWARES\tHANDLER\tTAGS\n"))
+	for _, route := range t {
+		_, _ = w.Write([]byte(strings.Join([]string{
+			route.Name,
+			route.Host,
+			route.Path,
+			strings.Join(route.Methods, ","),
+			strings.Join(route.Groups, "."),
+			strings.Join(route.Middlewares, ","),
+			route.Handler,
+			route.Tags,
+		}, "\t") + "\n"))
+	}
+	_ = w.Flush()
+	return builder.String()
+}
+
+// RouteTableHandler returns a debug handler that serves the route table of the registry,
+// as JSON by default or as a text table when the format query argument is "text".
+func RouteTableHandler(registry RouterRegistry) Router {
+	return func(ctx *fasthttp.RequestCtx) {
+		routes := registry.Routes()
+		if string(ctx.QueryArgs().Peek("format")) == "text" {
+			ctx.SetContentType(eighty.TextContentUTF8Type[0])
+			ctx.SetBodyString(routes.String())
+			return
+		}
+		eighty.DumpJSONFasthttp(ctx, fasthttp.StatusOK, routes)
+	}
+}
@@ -0,0 +1,79 @@
+package routing
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spi-ca/eighty"
+	"github.com/valyala/fasthttp"
+)
+
+func inventoryAudit(next Router) Router { return next }
+
+func inventoryAuth(next Router) Router { return next }
+
+func inventoryUsers(ctx *fasthttp.RequestCtx) { ctx.SetBodyString("users") }
+
+func inventoryRegistry() RouterRegistry {
+	registry := NewRouterContext("", NewUrlFor()).BuildRouter(nil)
+	registry.Register("index", "/", nil, inventoryUsers, nil, fasthttp.MethodGet)
+	api := registry.Wrap("api", "/api", inventoryAudit)
+	api.Register("users", "/users/{id:[0-9]+}", nil, inventoryUsers, []Middleware{inventoryAuth}, fasthttp.MethodGet, fasthttp.MethodDelete)
+	api.Tag("users", `auth:"admin" audit:"true"`)
+	registry.WrapHost("tenant", "{tenant}.example.com").Register("home", "/home", nil, inventoryUsers, nil, fasthttp.MethodGet)
+	return registry
+}
+
+func TestRouteTable(t *testing.T) {
+	routes := inventoryRegistry().Routes()
+	want := RouteTable{
+		{
+			Name: "index", Path: "/", Methods: []string{fasthttp.MethodGet},
+			Groups: []string{}, Middlewares: []string{}, Handler: "github.com/spi-ca/eighty/routing.inventoryUsers",
+		},
+		{
+			Name: "api.users", Path: "/api/users/{id:[0-9]+}", Methods: []string{fasthttp.MethodGet, fasthttp.MethodDelete},
+			Groups: []string{"api"},
+			Middlewares: []string{
+				"github.com/spi-ca/eighty/routing.inventoryAudit",
+				"github.com/spi-ca/eighty/routing.inventoryAuth",
+			},
+			Handler: "github.com/spi-ca/eighty/routing.inventoryUsers",
+			Tags:    `auth:"admin" audit:"true"`,
+		},
+		{
+			Name: "tenant.home", Host: "{tenant}.example.com", Path: "/home", Methods: []string{fasthttp.MethodGet},
+			Groups: []string{"tenant"}, Middlewares: []string{}, Handler: "github.com/spi-ca/eighty/routing.inventoryUsers",
+		},
+	}
+	if !reflect.DeepEqual(routes, want) {
+		t.Errorf("routes\n%+v\nwant\n%+v", routes, want)
+	}
+	if filtered := routes.Filter(func(route RouteInfo) bool { return len(route.Host) > 0 }); len(filtered) != 1 || filtered[0].Name != "tenant.home" {
+		t.Errorf("filtered %+v", filtered)
+	}
+	compareGolden(t, "routes.txt", []byte(routes.String()))
+}
+
+func TestRouteTableHandler(t *testing.T) {
+	handler := RouteTableHandler(inventoryRegistry())
+
+	ctx := serve(t, handler, fasthttp.MethodGet, "/routes")
+	var routes RouteTable
+	if contentType := string(ctx.Response.Header.ContentType()); !strings.HasPrefix(contentType, "application/json") {
+		t.Errorf("content type %q", contentType)
+	} else if err := json.Unmarshal(ctx.Response.Body(), &routes); err != nil {
+		t.Fatal(err)
+	} else if len(routes) != 3 || routes[1].Tags != `auth:"admin" audit:"true"` {
+		t.Errorf("decoded %+v", routes)
+	}
+
+	ctx = serve(t, handler, fasthttp.MethodGet, "/routes?format=text")
+	if contentType := string(ctx.Response.Header.ContentType()); contentType != eighty.TextContentUTF8Type[0] {
+		t.Errorf("content type %q", contentType)
+	} else if body := string(ctx.Response.Body()); !strings.HasPrefix(body, "NAME") || !strings.Contains(body, "TAGS") {
+		t.Errorf("text table:\n%s", body)
+	}
+}
@@ -0,0 +1,143 @@
+package routing
+
+import (
+	"context"
+	"github.com/spi-ca/eighty"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+const (
+	// MountPathParam is the catch-all param of a mounted handler, holding the path below the mount point.
+	MountPathParam = "filepath"
+	// MountRootSuffix is appended to the name of a mounted handler to name the route of the mount point itself.
+	MountRootSuffix = "Root"
+
+	// the key of the request that the last net/http middleware passed on.
+	// fasthttp only keys user values by string, the package prefix keeps the internal keys apart from route params.
+	httpRequestContextKey = "routing.httpRequest"
+)
+
+// mountMethods are the methods a mounted handler serves, it answers OPTIONS itself.
+var mountMethods = []string{
+	fasthttp.MethodGet, fasthttp.MethodHead, fasthttp.MethodPost, fasthttp.MethodPut,
+	fasthttp.MethodPatch, fasthttp.MethodDelete, fasthttp.MethodOptions,
+}
+
+type (
+	// httpResponseBridge is a http.ResponseWriter that writes through to the fasthttp response.
+	httpResponseBridge struct {
+		ctx         *fasthttp.RequestCtx
+		header      http.Header
+		wroteHeader bool
+	}
+)
+
+// HTTPHandler adapts the net/http handler to a Router.
+// The route params and user values are available from the request context by their name, see HTTPRouteParam.
+func HTTPHandler(handler http.Handler) Router {
+	return fasthttpadaptor.NewFastHTTPHandler(handler)
+}
+
+// HTTPRouteParam returns the route param or the string user value of a request served through HTTPHandler or HTTPMiddleware.
+func HTTPRouteParam(r *http.Request, name string) string {
+	value, _ := r.Context().Value(name).(string)
+	return value
+}
+
+// HTTPRequestContext returns the context of the request as passed on by the last net/http middleware,
+// which holds the values those middlewares attached. Without such middleware, the request itself is returned.
+func HTTPRequestContext(ctx *fasthttp.RequestCtx) context.Context {
+	if r, ok := ctx.UserValue(httpRequestContextKey).(*http.Request); ok {
+		return r.Context()
+	}
+	return ctx
+}
+
+// HTTPMiddleware converts the net/http middleware to a Middleware.
+// Response headers and statuses the middleware writes go to the fasthttp response, and a middleware may answer
+// without calling the next handler. Request header and URI changes are copied back before the next handler runs,
+// and the context values are available through HTTPRequestContext.
+// Response writes of the next handler are not visible to the middleware.
+func HTTPMiddleware(middleware func(http.Handler) http.Handler) Middleware {
+	return func(next Router) Router {
+		return func(ctx *fasthttp.RequestCtx) {
+			var req http.Request
+			if err := fasthttpadaptor.ConvertRequest(ctx, &req, true); err != nil {
+				panic(eighty.HandledErrorInternalServerError)
+			}
+			w := &httpResponseBridge{ctx: ctx, header: make(http.Header)}
+			middleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				w.flushHeader()
+				copyHTTPRequest(ctx, r)
+				ctx.SetUserValue(httpRequestContextKey, r)
+				next(ctx)
+			})).ServeHTTP(w, req.WithContext(ctx))
+		}
+	}
+}
+
+// copyHTTPRequest copies the header and URI changes of the net/http request back to the fasthttp request.
+func copyHTTPRequest(ctx *fasthttp.RequestCtx, r *http.Request) {
+	var removed []string
+	ctx.Request.Header.VisitAll(func(key, _ []byte) {
+		if name := string(key); name != fasthttp.HeaderHost && len(r.Header.Values(name)) == 0 {
+			removed = append(removed, name)
+		}
+	})
+	for _, name := range removed {
+		ctx.Request.Header.Del(name)
+	}
+	for name, values := range r.Header {
+		ctx.Request.Header.Del(name)
+		for _, value := range values {
+			ctx.Request.Header.Add(name, value)
+		}
+	}
+	if requestURI := r.URL.RequestURI(); requestURI != string(ctx.RequestURI()) {
+		ctx.Request.SetRequestURI(requestURI)
+	}
+}
+
+func (w *httpResponseBridge) Header() http.Header { return w.header }
+
+// flushHeader copies the header map to the fasthttp response.
+func (w *httpResponseBridge) flushHeader() {
+	for name, values := range w.header {
+		w.ctx.Response.Header.Del(name)
+		for _, value := range values {
+			w.ctx.Response.Header.Add(name, value)
+		}
+	}
+}
+
+func (w *httpResponseBridge) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.flushHeader()
+	w.ctx.SetStatusCode(statusCode)
+}
+
+func (w *httpResponseBridge) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.ctx.Write(p)
+}
+
+func (r *routerRegistryImpl) Mount(name string, path string, handler http.Handler, middlewares []Middleware) {
+	handlerName := funcName(handler)
+	if len(handlerName) == 0 {
+		handlerName = reflect.TypeOf(handler).String()
+	}
+	root := strings.TrimSuffix(path, "/")
+	routed := HTTPHandler(handler)
+	// the root goes first, as the router rejects it once the catch-all route redirects it
+	if len(root) > 0 {
+		r.register(name+MountRootSuffix, root, nil, routed, handlerName, middlewares, mountMethods...)
+	}
+	r.register(name, root+"/{"+MountPathParam+":*}", nil, routed, handlerName, middlewares, mountMethods...)
+}
@@ -0,0 +1,109 @@
+package routing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/spi-ca/eighty"
+	"github.com/valyala/fasthttp"
+)
+
+type nethttpTestKey struct{}
+
+func TestMount(t *testing.T) {
+	routerCtx := NewRouterContext("", NewUrlFor())
+	registry := routerCtx.BuildRouter(nil)
+	registry.Mount("static", "/static/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		meta := HTTPCurrentRoute(r)
+		w.Header().Set("X-Route", meta.Name)
+		_, _ = w.Write([]byte(r.URL.Path + " " + HTTPRouteParam(r, MountPathParam)))
+	}), nil)
+
+	tests := []struct {
+		method    string
+		uri       string
+		wantBody  string
+		wantRoute string
+	}{
+		{method: fasthttp.MethodGet, uri: "/static", wantBody: "/static ", wantRoute: "staticRoot"},
+		{method: fasthttp.MethodPost, uri: "/static", wantBody: "/static ", wantRoute: "staticRoot"},
+		{method: fasthttp.MethodGet, uri: "/static/css/app.css", wantBody: "/static/css/app.css css/app.css", wantRoute: "static"},
+	}
+	for _, test := range tests {
+		t.Run(test.method+" "+test.uri, func(t *testing.T) {
+			ctx := serve(t, registry.Handler, test.method, test.uri)
+			if status := ctx.Response.StatusCode(); status != fasthttp.StatusOK {
+				t.Fatalf("status %d, want %d", status, fasthttp.StatusOK)
+			} else if body := string(ctx.Response.Body()); body != test.wantBody {
+				t.Errorf("body %q, want %q", body, test.wantBody)
+			} else if route := string(ctx.Response.Header.Peek("X-Route")); route != test.wantRoute {
+				t.Errorf("route %q, want %q", route, test.wantRoute)
+			}
+		})
+	}
+
+	if ctx := serve(t, registry.Handler, fasthttp.MethodGet, "/static/"); ctx.Response.StatusCode() != fasthttp.StatusMovedPermanently {
+		t.Errorf("status %d, want the redirect to the mount point", ctx.Response.StatusCode())
+	} else if location := string(ctx.Response.Header.Peek(fasthttp.HeaderLocation)); !strings.HasSuffix(location, "/static") {
+		t.Errorf("redirected to %q", location)
+	}
+
+	resolver := routerCtx.UrlResolver()
+	if url := resolver.MustReverse("staticRoot"); url != "/static" {
+		t.Errorf("reversed the mount point to %q", url)
+	} else if url = resolver.MustReverse("static", "css/app.css"); url != "/static/css/app.css" {
+		t.Errorf("reversed the mounted path to %q", url)
+	}
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	middleware := HTTPMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Deny") != "" {
+				w.WriteHeader(http.StatusTeapot)
+				return
+			}
+			w.Header().Set("X-Middleware", "seen")
+			r.Header.Set("X-Forwarded", "yes")
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), nethttpTestKey{}, "value")))
+		})
+	})
+	handler := ApplyMiddlware(func(ctx *fasthttp.RequestCtx) {
+		value, _ := HTTPRequestContext(ctx).Value(nethttpTestKey{}).(string)
+		ctx.SetBodyString(value + " " + string(ctx.Request.Header.Peek("X-Forwarded")))
+	}, middleware)
+
+	ctx := serve(t, handler, fasthttp.MethodGet, "/")
+	if body := string(ctx.Response.Body()); body != "value yes" {
+		t.Errorf("body %q, want the context value and the changed header", body)
+	} else if header := string(ctx.Response.Header.Peek("X-Middleware")); header != "seen" {
+		t.Errorf("middleware header %q", header)
+	}
+	if ctx = serve(t, handler, fasthttp.MethodGet, "/", "X-Deny", "1"); ctx.Response.StatusCode() != http.StatusTeapot {
+		t.Errorf("status %d, want the answer of the middleware", ctx.Response.StatusCode())
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != eighty.HandledErrorInternalServerError {
+			t.Errorf("panicked with %v, want %v", recovered, eighty.HandledErrorInternalServerError)
+		}
+	}()
+	serve(t, handler, fasthttp.MethodGet, "/%zz")
+	t.Error("the unconvertible request was served")
+}
+
+func TestContextKeysAvoidRouteParams(t *testing.T) {
+	registry := NewRouterContext("", NewUrlFor()).BuildRouter(nil)
+	registry.Register("meta", "/meta/{routeMeta}", nil, func(ctx *fasthttp.RequestCtx) {
+		if meta := CurrentRoute(ctx); meta == nil || meta.Name != "meta" {
+			t.Errorf("route metadata %+v", meta)
+		}
+		ctx.SetBodyString(ctx.UserValue("routeMeta").(string))
+	}, nil, fasthttp.MethodGet)
+
+	if body := string(serve(t, registry.Handler, fasthttp.MethodGet, "/meta/param").Response.Body()); body != "param" {
+		t.Errorf("body %q, want the route param", body)
+	}
+}
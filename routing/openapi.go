@@ -0,0 +1,444 @@
+package routing
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/spi-ca/eighty"
+	"github.com/spi-ca/misc"
+	"github.com/valyala/fasthttp"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const openAPIVersion = "3.1.0"
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	byteSliceType = reflect.TypeOf([]byte(nil))
+)
+
+type (
+	// Operation is the optional OpenAPI metadata of a route.
+	Operation struct {
+		Summary     string   `json:"summary,omitempty"`
+		Description string   `json:"description,omitempty"`
+		Tags        []string `json:"tags,omitempty"`
+		// Request is a value or a reflect.Type of the JSON request body, nil if none.
+		Request any `json:"-"`
+		// RequestOptional marks the request body optional, it is required otherwise.
+		RequestOptional bool `json:"requestOptional,omitempty"`
+		// Response is a value or a reflect.Type of the JSON response body, nil if none.
+		Response any `json:"-"`
+		// ErrorStatuses lists the error http statuses the route responds with.
+		ErrorStatuses []int `json:"errorStatuses,omitempty"`
+	}
+
+	// schemaRegistry reflects Go types into the components/schemas of the document.
+	schemaRegistry struct {
+		schemas map[string]any
+		names   map[reflect.Type]string
+	}
+)
+
+// OpenAPIDocument generates an OpenAPI 3.1 document of the routes, with the schemas reflected from
+// the Go types of their operations. servers lists the base URLs of the API.
+// A document describes a single host and version, so the routes of the host groups and of the versions
+// selected by header or media type that share a method and path fail with an error; filter them apart first.
+func OpenAPIDocument(routes RouteTable, title, version string, servers ...string) (map[string]any, error) {
+	registry := &schemaRegistry{
+		schemas: make(map[string]any),
+		names:   make(map[reflect.Type]string),
+	}
+	var (
+		paths = make(map[string]any)
+		// documented are the route names by operation
+		documented = make(map[string]string)
+	)
+	for _, route := range routes {
+		segments, err := parseRoutePattern(route.Path)
+		if err != nil {
+			return nil, errors.New("cannot document the route " + route.Name + ": " + err.Error())
+		}
+		path, parameters := openAPIPath(segments)
+		pathItem, ok := paths[path].(map[string]any)
+		if !ok {
+			pathItem = make(map[string]any)
+			paths[path] = pathItem
+		}
+		for _, method := range route.Methods {
+			key := method + " " + path
+			if other, taken := documented[key]; taken {
+				return nil, errors.New("the routes " + other + " and " + route.Name + " share the operation " + key)
+			}
+			documented[key] = route.Name
+			operationID := route.Name
+			if len(route.Methods) > 1 {
+				operationID += "." + strings.ToLower(method)
+			}
+			pathItem[strings.ToLower(method)] = registry.operation(route.Operation, operationID, method, parameters)
+		}
+	}
+
+	document := map[string]any{
+		"openapi": openAPIVersion,
+		"info": map[string]any{
+			"title":   title,
+			"version": version,
+		},
+		"paths": paths,
+	}
+	if len(servers) > 0 {
+		serverList := make([]any, len(servers))
+		for i, server := range servers {
+			serverList[i] = map[string]any{"url": server}
+		}
+		document["servers"] = serverList
+	}
+	if len(registry.schemas) > 0 {
+		document["components"] = map[string]any{"schemas": registry.schemas}
+	}
+	return document, nil
+}
+
+// OpenAPIHandler returns a handler that serves the OpenAPI document of the registry routes that filter accepts,
+// all of them if filter is nil, as JSON by default or as YAML when the format query argument is "yaml"
+// or the path ends with ".yaml". The document errors, see OpenAPIDocument, are rendered as a server error.
+func OpenAPIHandler(registry RouterRegistry, filter func(route RouteInfo) bool, title, version string, servers ...string) Router {
+	return func(ctx *fasthttp.RequestCtx) {
+		routes := registry.Routes()
+		if filter != nil {
+			routes = routes.Filter(filter)
+		}
+		document, err := OpenAPIDocument(routes, title, version, servers...)
+		if err != nil {
+			panic(err)
+		}
+		if string(ctx.QueryArgs().Peek("format")) == "yaml" || strings.HasSuffix(string(ctx.Path()), ".yaml") {
+			ctx.SetContentType("application/yaml; charset=utf-8")
+			ctx.SetBody(MarshalYAML(document))
+			return
+		}
+		body, err := MarshalOpenAPIJSON(document)
+		if err != nil {
+			panic(err)
+		}
+		ctx.SetContentType(eighty.JsonContentUTF8Type[0])
+		ctx.SetBody(body)
+	}
+}
+
+// MarshalOpenAPIJSON returns the document encoded as indented JSON, with the keys sorted.
+func MarshalOpenAPIJSON(document map[string]any) ([]byte, error) {
+	return json.MarshalIndent(document, "", "  ")
+}
+
+// openAPIPath converts the router pattern into an OpenAPI path template and its path parameters.
+func openAPIPath(segments []routeSegment) (string, []any) {
+	var (
+		builder    strings.Builder
+		parameters []any
+	)
+	for _, segment := range segments {
+		if len(segment.param) == 0 {
+			builder.WriteString(segment.literal)
+			continue
+		}
+		builder.WriteString("{" + segment.param + "}")
+		schema := map[string]any{"type": "string"}
+		if len(segment.pattern) > 0 {
+			schema["pattern"] = "^(?:" + segment.pattern + ")$"
+		}
+		parameters = append(parameters, map[string]any{
+			"name":     segment.param,
+			"in":       "path",
+			"required": !segment.optional,
+			"schema":   schema,
+		})
+	}
+	return builder.String(), parameters
+}
+
+func (r *schemaRegistry) operation(op *Operation, operationID, method string, parameters []any) map[string]any {
+	operation := map[string]any{"operationId": operationID}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+	responses := make(map[string]any)
+	operation["responses"] = responses
+	if op == nil {
+		responses["default"] = map[string]any{"description": "Response"}
+		return operation
+	}
+
+	if len(op.Summary) > 0 {
+		operation["summary"] = op.Summary
+	}
+	if len(op.Description) > 0 {
+		operation["description"] = op.Description
+	}
+	if len(op.Tags) > 0 {
+		tags := make([]any, len(op.Tags))
+		for i, tag := range op.Tags {
+			tags[i] = tag
+		}
+		operation["tags"] = tags
+	}
+	if op.Request != nil {
+		operation["requestBody"] = map[string]any{
+			"required": !op.RequestOptional,
+			"content":  r.jsonContent(op.Request),
+		}
+	}
+
+	success := map[string]any{"description": http.StatusText(http.StatusOK)}
+	if op.Response != nil {
+		success["content"] = r.jsonContent(op.Response)
+	} else if method != http.MethodGet && method != http.MethodHead {
+		responses[strconv.Itoa(http.StatusNoContent)] = map[string]any{"description": http.StatusText(http.StatusNoContent)}
+		success = nil
+	}
+	if success != nil {
+		responses[strconv.Itoa(http.StatusOK)] = success
+	}
+	for _, status := range op.ErrorStatuses {
+		responses[strconv.Itoa(status)] = map[string]any{"description": http.StatusText(status)}
+	}
+	return operation
+}
+
+func (r *schemaRegistry) jsonContent(sample any) map[string]any {
+	t, ok := sample.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(sample)
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return map[string]any{
+		eighty.JsonContentType[0]: map[string]any{"schema": r.schema(t)},
+	}
+}
+
+// schema returns the JSON schema of the type, named struct types are referenced from the components.
+func (r *schemaRegistry) schema(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == byteSliceType:
+		return map[string]any{"type": "string", "contentEncoding": "base64"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := r.schema(t.Elem())
+		if ref, isRef := schema["$ref"]; isRef {
+			return map[string]any{"oneOf": []any{map[string]any{"$ref": ref}, map[string]any{"type": "null"}}}
+		} else if typ, hasType := schema["type"].(string); hasType {
+			schema["type"] = []any{typ, "null"}
+		}
+		return schema
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32:
+		return map[string]any{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]any{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": r.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": r.schema(t.Elem())}
+	case reflect.Struct:
+		if len(t.Name()) == 0 {
+			return r.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + r.named(t)}
+	default:
+		return map[string]any{}
+	}
+}
+
+// named registers the named struct type in the components and returns its schema name.
+func (r *schemaRegistry) named(t reflect.Type) string {
+	if name, ok := r.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := r.schemas[name]; taken {
+		pkgPath := t.PkgPath()
+		name = pkgPath[strings.LastIndexByte(pkgPath, '/')+1:] + "." + name
+	}
+	// register the name first, so that recursive types refer to themselves
+	r.names[t] = name
+	r.schemas[name] = map[string]any{}
+	r.schemas[name] = r.structSchema(t)
+	return name
+}
+
+func (r *schemaRegistry) structSchema(t reflect.Type) map[string]any {
+	var (
+		properties = make(map[string]any)
+		required   []any
+	)
+	r.collectFields(t, properties, &required)
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Slice(required, func(i, j int) bool { return required[i].(string) < required[j].(string) })
+		schema["required"] = required
+	}
+	return schema
+}
+
+// collectFields adds the JSON fields of the struct, flattening the embedded structs like encoding/json.
+func (r *schemaRegistry) collectFields(t reflect.Type, properties map[string]any, required *[]any) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		fieldType := field.Type
+		if field.Anonymous && len(name) == 0 {
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				r.collectFields(fieldType, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+
+		schema := r.schema(fieldType)
+		if strings.Contains(options, "string") {
+			schema = map[string]any{"type": "string"}
+		}
+		properties[name] = schema
+		if !strings.Contains(options, "omitempty") && fieldType.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// MarshalYAML returns the document encoded as YAML, with the keys sorted.
+func MarshalYAML(document map[string]any) []byte {
+	var builder strings.Builder
+	writeYAMLMap(&builder, document, 0, false)
+	return []byte(builder.String())
+}
+
+// writeYAMLMap writes the entries of the map, the first one inline after a "- " marker if inList.
+func writeYAMLMap(builder *strings.Builder, value map[string]any, indent int, inList bool) {
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		if i > 0 || !inList {
+			builder.WriteString(strings.Repeat("  ", indent))
+		}
+		builder.WriteString(yamlScalar(key) + ":")
+		writeYAMLValue(builder, value[key], indent)
+	}
+}
+
+// writeYAMLValue writes the value that follows a "key:" marker.
+func writeYAMLValue(builder *strings.Builder, value any, indent int) {
+	switch typed := value.(type) {
+	case map[string]any:
+		if len(typed) == 0 {
+			builder.WriteString(" {}\n")
+			return
+		}
+		builder.WriteByte('\n')
+		writeYAMLMap(builder, typed, indent+1, false)
+	case []any:
+		if len(typed) == 0 {
+			builder.WriteString(" []\n")
+			return
+		}
+		builder.WriteByte('\n')
+		for _, item := range typed {
+			builder.WriteString(strings.Repeat("  ", indent+1) + "- ")
+			if itemMap, ok := item.(map[string]any); ok && len(itemMap) > 0 {
+				writeYAMLMap(builder, itemMap, indent+2, true)
+			} else {
+				builder.WriteString(yamlScalar(item) + "\n")
+			}
+		}
+	default:
+		builder.WriteString(" " + yamlScalar(typed) + "\n")
+	}
+}
+
+func yamlScalar(value any) string {
+	switch typed := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(typed)
+	case int:
+		return strconv.Itoa(typed)
+	case float64:
+		return strconv.FormatFloat(typed, 'g', -1, 64)
+	case string:
+		if yamlPlainString(typed) {
+			return typed
+		}
+		// double quoted scalars share the escaping of Go and JSON strings
+		return strconv.Quote(typed)
+	case map[string]any:
+		return "{}"
+	case []any:
+		return "[]"
+	default:
+		encoded, _ := misc.JSONCodec.Marshal(typed)
+		return string(encoded)
+	}
+}
+
+// yamlPlainString reports whether the string can be written unquoted without being read as another type.
+func yamlPlainString(value string) bool {
+	if len(value) == 0 {
+		return false
+	}
+	switch strings.ToLower(value) {
+	case "true", "false", "null", "yes", "no", "on", "off", "y", "n", "~":
+		return false
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return false
+	}
+	for i, c := range value {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_', c == '/':
+		case c >= '0' && c <= '9', c == '.', c == '-', c == '$':
+			if i == 0 && c == '-' {
+				return false
+			}
+		case c == '{' || c == '}':
+			if i == 0 {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package routing
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files of the tests")
+
+type (
+	openAPIAddress struct {
+		City string `json:"city"`
+		Zip  string `json:"zip,omitempty"`
+	}
+	openAPIUser struct {
+		ID        int64           `json:"id,string"`
+		Name      string          `json:"name"`
+		Nickname  *string         `json:"nickname"`
+		Address   *openAPIAddress `json:"address,omitempty"`
+		Avatar    []byte          `json:"avatar,omitempty"`
+		CreatedAt time.Time       `json:"createdAt"`
+		Labels    map[string]bool `json:"labels,omitempty"`
+		internal  bool
+	}
+	openAPIUserPatch struct {
+		Name *string `json:"name,omitempty"`
+	}
+)
+
+// compareGolden compares the output with the golden file in testdata, rewriting it with -update.
+func compareGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	} else if string(got) != string(want) {
+		t.Errorf("%s differs from the golden file, got:\n%s", name, got)
+	}
+}
+
+func openAPIRegistry() RouterRegistry {
+	registry := NewRouterContext("", NewUrlFor()).BuildRouter(nil)
+	api := registry.Wrap("api", "/api")
+	api.Register("users", "/users", nil, writeBody("users"), nil, fasthttp.MethodGet, fasthttp.MethodPost)
+	api.Describe("users", Operation{
+		Summary:       "Users",
+		Tags:          []string{"users"},
+		Request:       openAPIUser{},
+		Response:      []openAPIUser{},
+		ErrorStatuses: []int{fasthttp.StatusBadRequest},
+	})
+	api.Register("user", "/users/{id:[0-9]+}", nil, writeBody("user"), nil, fasthttp.MethodPatch)
+	api.Describe("user", Operation{
+		Summary:         "Update the user",
+		Description:     "Changes the given fields: name",
+		Request:         &openAPIUserPatch{},
+		RequestOptional: true,
+		ErrorStatuses:   []int{fasthttp.StatusNotFound},
+	})
+	api.Register("file", "/files/{name?}", nil, writeBody("file"), nil, fasthttp.MethodGet)
+	return registry
+}
+
+func TestOpenAPIDocumentGolden(t *testing.T) {
+	document, err := OpenAPIDocument(openAPIRegistry().Routes(), "Example API", "1.0.0", "https://api.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := MarshalOpenAPIJSON(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareGolden(t, "openapi.json", append(encoded, '\n'))
+	compareGolden(t, "openapi.yaml", MarshalYAML(document))
+}
+
+func TestOpenAPIDocumentConflicts(t *testing.T) {
+	registry := NewRouterContext("", NewUrlFor()).BuildRouter(nil)
+	registry.Register("users", "/users", nil, writeBody("users"), nil, fasthttp.MethodGet)
+	registry.WrapHost("tenant", "{tenant}.example.com").
+		Register("users", "/users", nil, writeBody("tenant users"), nil, fasthttp.MethodGet)
+	versions := registry.WrapVersions("api", "/api", VersionByHeader("X-API-Version"), "1")
+	versions.Version("1", time.Time{}, time.Time{}).Register("items", "/items", nil, writeBody("v1"), nil, fasthttp.MethodGet)
+	versions.Version("2", time.Time{}, time.Time{}).Register("items", "/items", nil, writeBody("v2"), nil, fasthttp.MethodGet)
+
+	if _, err := OpenAPIDocument(registry.Routes(), "Example API", "1.0.0"); err == nil || !strings.Contains(err.Error(), "GET /users") {
+		t.Errorf("got %v, want the conflict of the host group route", err)
+	}
+	routes := registry.Routes().Filter(func(route RouteInfo) bool { return len(route.Host) == 0 })
+	if _, err := OpenAPIDocument(routes, "Example API", "1.0.0"); err == nil || !strings.Contains(err.Error(), "GET /api/items") {
+		t.Errorf("got %v, want the conflict of the versions", err)
+	}
+	routes = routes.Filter(func(route RouteInfo) bool { return route.Version != "1" })
+	document, err := OpenAPIDocument(routes, "Example API", "2")
+	if err != nil {
+		t.Fatal(err)
+	} else if paths := document["paths"].(map[string]any); len(paths) != 2 {
+		t.Errorf("documented %d paths, want 2", len(paths))
+	}
+
+	broken := RouteTable{{Name: "broken", Path: "/files/{name", Methods: []string{fasthttp.MethodGet}}}
+	if _, err = OpenAPIDocument(broken, "Example API", "1.0.0"); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("got %v, want the error of the unparsable path", err)
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	registry := openAPIRegistry()
+	handler := OpenAPIHandler(registry, func(route RouteInfo) bool { return route.Name != "api.file" }, "Example API", "1.0.0")
+
+	ctx := serve(t, handler, fasthttp.MethodGet, "/openapi.yaml")
+	if contentType := string(ctx.Response.Header.ContentType()); !strings.HasPrefix(contentType, "application/yaml") {
+		t.Errorf("content type %q", contentType)
+	} else if body := string(ctx.Response.Body()); !strings.Contains(body, "/api/users:") || strings.Contains(body, "/api/files") {
+		t.Errorf("yaml document:\n%s", body)
+	}
+	ctx = serve(t, handler, fasthttp.MethodGet, "/openapi")
+	if contentType := string(ctx.Response.Header.ContentType()); !strings.HasPrefix(contentType, "application/json") {
+		t.Errorf("content type %q", contentType)
+	}
+}
@@ -0,0 +1,110 @@
+package routing
+
+import (
+	"github.com/fasthttp/router"
+	"github.com/spi-ca/eighty"
+	"github.com/spi-ca/misc/strutil"
+	"github.com/valyala/fasthttp"
+	"sort"
+	"strings"
+)
+
+// the key of the mark that makes the OPTIONS route of a path only set the Allow header
+const allowOnlyContextKey = "routing.allowOnly"
+
+// optionsRoute is the OPTIONS handler of a path, the automatic one until an explicit OPTIONS route replaces it.
+// It keeps the methods registered on the path, which the Allow header of both its responses and the 405 responses lists.
+type optionsRoute struct {
+	r        *router.Router
+	handler  Router
+	methods  []string
+	auto     bool
+	disabled bool
+}
+
+// allow returns the Allow header value of the path, the registered methods and OPTIONS unless the path opted out.
+func (o *optionsRoute) allow() string {
+	methods := append([]string(nil), o.methods...)
+	if !o.auto || !o.disabled {
+		methods = append(methods, fasthttp.MethodOptions)
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
+// serve answers the OPTIONS request, an opted out path responds as if it had no OPTIONS route.
+func (o *optionsRoute) serve(ctx *fasthttp.RequestCtx) {
+	ctx.Response.Header.Set(eighty.AllowHeader, o.allow())
+	if ctx.UserValue(allowOnlyContextKey) != nil {
+		return
+	} else if !o.auto || !o.disabled {
+		o.handler(ctx)
+	} else {
+		o.r.MethodNotAllowed(ctx)
+	}
+}
+
+// setAllow sets the Allow header of the request path to the value of its OPTIONS route, if any.
+func setAllow(r *router.Router, ctx *fasthttp.RequestCtx) {
+	if handler, _ := r.Lookup(fasthttp.MethodOptions, strutil.B2S(ctx.Request.URI().PathOriginal()), nil); handler != nil {
+		ctx.SetUserValue(allowOnlyContextKey, true)
+		handler(ctx)
+		ctx.SetUserValue(allowOnlyContextKey, nil)
+	}
+}
+
+// answerOptions answers the OPTIONS request, the Allow header is set by the OPTIONS route.
+func answerOptions(ctx *fasthttp.RequestCtx) {
+	ctx.SetStatusCode(fasthttp.StatusNoContent)
+}
+
+// optionsRoute returns the OPTIONS route of the path, registering the automatic handler
+// wrapped by the group middlewares when the path is first added with the route of the metadata.
+func (r *routerRegistryImpl) optionsRoute(path string, meta *RouteMeta) *optionsRoute {
+	inv := r.inventory
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	// the paths are keyed with their host pattern, as every host group has its own router
+	key := r.host + path
+	if options, ok := inv.options[key]; ok {
+		return options
+	}
+	if inv.options == nil {
+		inv.options = make(map[string]*optionsRoute)
+	}
+	options := &optionsRoute{
+		r:       r.r,
+		handler: withRouteMeta(meta, ApplyMiddlware(answerOptions, r.middlewares...)),
+		auto:    true,
+	}
+	inv.options[key] = options
+	r.r.Handle(fasthttp.MethodOptions, path, options.serve)
+	return options
+}
+
+// handle registers the handler of the method, an OPTIONS handler replaces the automatic one of the path.
+func (r *routerRegistryImpl) handle(method, path string, handler Router, meta *RouteMeta) {
+	options := r.optionsRoute(path, meta)
+	if method != fasthttp.MethodOptions {
+		options.methods = append(options.methods, method)
+		r.r.Handle(method, path, handler)
+	} else if options.auto {
+		options.handler, options.auto = handler, false
+	} else {
+		panic("a handler is already registered for method OPTIONS and path " + path)
+	}
+}
+
+func (r *routerRegistryImpl) DisableOptions(name string) {
+	fullName := strings.Join(append(append([]string(nil), r.parentNames...), r.versionedName(name)), ".")
+	inv := r.inventory
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	for _, route := range inv.routes {
+		if route.Name == fullName {
+			inv.options[route.Host+route.Path].disabled = true
+			return
+		}
+	}
+	panic("cannot disable options of the unregistered route " + fullName)
+}
@@ -0,0 +1,105 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/spi-ca/eighty"
+	"github.com/valyala/fasthttp"
+)
+
+func TestOptionsAndMethodNotAllowed(t *testing.T) {
+	registry := NewRouterContext("", NewUrlFor()).BuildRouter(nil)
+	registry.Register("users", "/users", nil, writeBody("users"), nil, fasthttp.MethodGet, fasthttp.MethodPost)
+	registry.Register("user", "/users/{id}", nil, writeBody("user"), nil, fasthttp.MethodGet, fasthttp.MethodDelete)
+	registry.Register("custom", "/custom", nil, writeBody("custom"), nil, fasthttp.MethodGet, fasthttp.MethodOptions)
+	registry.Register("hidden", "/hidden", nil, writeBody("hidden"), nil, fasthttp.MethodPut)
+	registry.DisableOptions("hidden")
+
+	tests := []struct {
+		name       string
+		method     string
+		uri        string
+		wantStatus int
+		wantAllow  string
+		wantBody   string
+	}{
+		{name: "automatic options", method: fasthttp.MethodOptions, uri: "/users", wantStatus: fasthttp.StatusNoContent, wantAllow: "GET, OPTIONS, POST"},
+		{name: "automatic options with params", method: fasthttp.MethodOptions, uri: "/users/7", wantStatus: fasthttp.StatusNoContent, wantAllow: "DELETE, GET, OPTIONS"},
+		{name: "405 lists options", method: fasthttp.MethodPatch, uri: "/users", wantStatus: fasthttp.StatusMethodNotAllowed, wantAllow: "GET, OPTIONS, POST"},
+		{name: "explicit options", method: fasthttp.MethodOptions, uri: "/custom", wantStatus: fasthttp.StatusOK, wantAllow: "GET, OPTIONS", wantBody: "custom"},
+		{name: "405 of explicit options", method: fasthttp.MethodPost, uri: "/custom", wantStatus: fasthttp.StatusMethodNotAllowed, wantAllow: "GET, OPTIONS"},
+		{name: "disabled options", method: fasthttp.MethodOptions, uri: "/hidden", wantStatus: fasthttp.StatusMethodNotAllowed, wantAllow: "PUT"},
+		{name: "405 of disabled options", method: fasthttp.MethodGet, uri: "/hidden", wantStatus: fasthttp.StatusMethodNotAllowed, wantAllow: "PUT"},
+		{name: "unknown path", method: fasthttp.MethodOptions, uri: "/unknown", wantStatus: fasthttp.StatusNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := serve(t, registry.Handler, test.method, test.uri)
+			if status := ctx.Response.StatusCode(); status != test.wantStatus {
+				t.Errorf("status %d, want %d", status, test.wantStatus)
+			}
+			if allow := string(ctx.Response.Header.Peek(eighty.AllowHeader)); allow != test.wantAllow {
+				t.Errorf("Allow %q, want %q", allow, test.wantAllow)
+			}
+			if len(test.wantBody) > 0 && string(ctx.Response.Body()) != test.wantBody {
+				t.Errorf("body %q, want %q", ctx.Response.Body(), test.wantBody)
+			}
+		})
+	}
+}
+
+func TestOptionsRunGroupMiddlewares(t *testing.T) {
+	registry := NewRouterContext("", NewUrlFor()).BuildRouter(nil)
+	group := registry.Wrap("api", "/api", func(next Router) Router {
+		return func(ctx *fasthttp.RequestCtx) {
+			ctx.Response.Header.Set("X-Group", "api")
+			next(ctx)
+		}
+	})
+	group.Register("items", "/items", nil, writeBody("items"), nil, fasthttp.MethodGet)
+
+	ctx := serve(t, registry.Handler, fasthttp.MethodOptions, "/api/items")
+	if value := string(ctx.Response.Header.Peek("X-Group")); value != "api" {
+		t.Errorf("group middleware header %q, want %q", value, "api")
+	} else if meta := CurrentRoute(ctx); meta == nil || meta.Name != "api.items" {
+		t.Errorf("route metadata %+v, want the api.items route", meta)
+	}
+}
+
+func TestDuplicateOptionsRoute(t *testing.T) {
+	registry := NewRouterContext("", NewUrlFor()).BuildRouter(nil)
+	registry.Register("first", "/path", nil, writeBody("first"), nil, fasthttp.MethodOptions)
+	defer func() {
+		if recover() == nil {
+			t.Fatal("a second OPTIONS route of the path did not panic")
+		}
+	}()
+	registry.Register("second", "/path", nil, writeBody("second"), nil, fasthttp.MethodOptions)
+}
+
+func TestMethodNotAllowedThroughErrorMiddleware(t *testing.T) {
+	renderError := func(next Router) Router {
+		return func(ctx *fasthttp.RequestCtx) {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					handled, _ := eighty.WrapHandledError(recovered)
+					handled.RenderAPI(ctx, nil)
+				}
+			}()
+			next(ctx)
+		}
+	}
+	registry := NewRouterContext("", NewUrlFor()).BuildRouter(renderError)
+	registry.Register("items", "/items", nil, writeBody("items"), nil, fasthttp.MethodGet)
+	registry.Register("locked", "/locked", nil, writeBody("locked"), nil, fasthttp.MethodGet)
+	registry.DisableOptions("locked")
+
+	for uri, wantAllow := range map[string]string{"/items": "GET, OPTIONS", "/locked": "GET"} {
+		ctx := serve(t, registry.Handler, fasthttp.MethodPut, uri)
+		if status := ctx.Response.StatusCode(); status != fasthttp.StatusMethodNotAllowed {
+			t.Errorf("%s: status %d, want %d", uri, status, fasthttp.StatusMethodNotAllowed)
+		} else if allow := string(ctx.Response.Header.Peek(eighty.AllowHeader)); allow != wantAllow {
+			t.Errorf("%s: Allow %q, want %q", uri, allow, wantAllow)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package routing
 
 import (
 	"github.com/fasthttp/router"
+	"github.com/spi-ca/eighty"
 	"github.com/valyala/fasthttp"
+	"net/http"
 	"strings"
 )
 
@@ -15,6 +17,8 @@ type (
 		// ToContext returns the current group name.
 		ToContext() RouterContext
 		// Register is a registration method for http request.
+		// params may be nil, the parameter names are derived from the path; if given, they must match the path.
+		// The route metadata is attached to the request before the middlewares run, see CurrentRoute.
 		Register(
 			name string, path string, params []string,
 			handler Router,
@@ -28,8 +32,49 @@ type (
 			middlewares []Middleware,
 			methods ...string,
 		)
+		// RegisterWebSocket is a registration method for WebSocket endpoint.
+		// The middlewares run before the upgrade, so they can reject the handshake.
+		RegisterWebSocket(
+			name string, path string, params []string,
+			upgrader eighty.WebSocketUpgrader,
+			handler func(conn eighty.WebSocketConn),
+			middlewares []Middleware,
+		)
+		// Mount registers the net/http handler for the path and every path below it, see HTTPHandler.
+		// The reverse route of the name takes the MountPathParam, the one of the name with MountRootSuffix is the path itself.
+		// The path with a trailing slash redirects to the path itself, as the router cannot serve both.
+		// The handler receives the full request URI, use http.StripPrefix with the path without its trailing slash
+		// to remove the mount point, and it answers OPTIONS requests itself.
+		Mount(name string, path string, handler http.Handler, middlewares []Middleware)
 		// Wrap returns a child RouterRegistry with specified name and path.
 		Wrap(name string, path string, middlewares ...Middleware) RouterRegistry
+		// WrapHost returns a child RouterRegistry whose routes only match the requests to the host pattern,
+		// such as "api.example.com" or "{tenant}.example.com". The host params are stored as user values
+		// and lead the params of the reverse URLs, which are host-qualified like "//acme.example.com/users".
+		// The paths the matching host group has no route for are served by the routes outside of the host groups.
+		// Host groups cannot be nested.
+		WrapHost(name string, host string, middlewares ...Middleware) RouterRegistry
+		// WrapVersions returns a versioned group with specified name and path, whose versions serve the same routes side by side.
+		// The scheme selects the version of a request, falling back to the default version.
+		// Versioned groups cannot be nested.
+		WrapVersions(
+			name string, path string,
+			scheme VersionScheme, defaultVersion string,
+			middlewares ...Middleware,
+		) VersionedRegistry
+		// Describe attaches the OpenAPI operation metadata to the registered route of the group.
+		// If the route is not registered, it panics.
+		Describe(name string, operation Operation)
+		// DisableOptions opts the path of the registered route out of the automatic OPTIONS handling,
+		// which is registered along with the first route of every path.
+		// If the route is not registered, it panics.
+		DisableOptions(name string)
+		// Tag attaches the tags in the struct tag syntax, such as `auth:"admin" audit:"true"`,
+		// to the metadata of the registered route of the group, see CurrentRoute.
+		// If the route is not registered, it panics.
+		Tag(name string, tags string)
+		// Routes returns the inventory of the routes registered on the router of the registry.
+		Routes() RouteTable
 		// Handler is a handler method that process incoming requests.
 		// It implements the Router interface.
 		Handler(ctx *fasthttp.RequestCtx)
@@ -41,11 +86,16 @@ type (
 		middlewares []Middleware
 		parentNames []string
 		parentPaths []string
+		inventory   *routeInventory
+		hosts       *hostRouting
+		host        string
+		versioning  *apiVersioning
+		version     string
 	}
 )
 
 func (r *routerRegistryImpl) Handler(ctx *fasthttp.RequestCtx) {
-	r.r.Handler(ctx)
+	r.hosts.route(ctx)
 }
 func (r *routerRegistryImpl) Name() string             { return r.name }
 func (r *routerRegistryImpl) ToContext() RouterContext { return r.routerContextImpl }
@@ -57,20 +107,115 @@ func (r *routerRegistryImpl) Register(
 	methods ...string,
 ) {
 
-	fullPath := r.reverseRouter.MustAddGr(name, path, r.parentNames, r.parentPaths, params...)
-	mixedRouter := ApplyMiddlware(handler, append(r.middlewares, middlewares...)...)
+	r.register(name, path, params, handler, funcName(handler), middlewares, methods...)
+}
+
+func (r *routerRegistryImpl) register(
+	name string, path string, params []string,
+	handler Router, handlerName string,
+	middlewares []Middleware,
+	methods ...string,
+) {
+	allMiddlewares := append(append([]Middleware(nil), r.middlewares...), middlewares...)
+	mixedRouter := ApplyMiddlware(handler, allMiddlewares...)
+	middlewareNames := make([]string, len(allMiddlewares))
+	for i, middleware := range allMiddlewares {
+		middlewareNames[i] = funcName(middleware)
+	}
+	// addRoute records the route and returns its handler, which attaches the route metadata
+	addRoute := func(urlName string, fullPath string) (Router, *RouteMeta) {
+		meta := &RouteMeta{
+			Name:    strings.Join(append(append([]string(nil), r.parentNames...), urlName), "."),
+			Groups:  append([]string{}, r.parentNames...),
+			Host:    r.host,
+			Path:    fullPath,
+			Version: r.version,
+		}
+		r.inventory.add(RouteInfo{
+			Name:        meta.Name,
+			Host:        r.host,
+			Path:        fullPath,
+			Version:     r.version,
+			Methods:     append([]string(nil), methods...),
+			Groups:      meta.Groups,
+			Middlewares: middlewareNames,
+			Handler:     handlerName,
+		}, meta)
+		return withRouteMeta(meta, mixedRouter), meta
+	}
+
+	fullPath := r.addReverse(r.versionedName(name), path, r.parentPaths, params)
+	routed, meta := addRoute(r.versionedName(name), fullPath)
 	for _, method := range methods {
-		r.r.Handle(method, fullPath, mixedRouter)
+		if r.versioning == nil {
+			r.handle(method, fullPath, routed, meta)
+		} else if dispatcher, ok := r.versioning.handle(r.version, method, fullPath, routed); ok {
+			r.handle(method, fullPath, dispatcher, meta)
+		}
+	}
+
+	if r.versioning != nil && r.version == r.versioning.defaultVersion {
+		// the default version is also served and reversed without the version
+		aliasPath := r.addReverse(name, path, r.versioning.unversionedPaths(r.parentPaths), params)
+		if aliasPath != fullPath {
+			aliasRouted, aliasMeta := addRoute(name, aliasPath)
+			for _, method := range methods {
+				r.handle(method, aliasPath, aliasRouted, aliasMeta)
+			}
+		}
 	}
 }
 
+// addReverse registers the reverse route of the group, returning the full path.
+func (r *routerRegistryImpl) addReverse(urlName, path string, parentPaths, params []string) string {
+	if len(r.host) > 0 {
+		return r.reverseRouter.MustAddHostGr(urlName, r.host, path, r.parentNames, parentPaths, params...)
+	}
+	return r.reverseRouter.MustAddGr(urlName, path, r.parentNames, parentPaths, params...)
+}
+
+// versionedName suffixes the route name with the version of the group, if any.
+func (r *routerRegistryImpl) versionedName(name string) string {
+	if r.versioning != nil {
+		return name + "@" + r.version
+	}
+	return name
+}
+
+func (r *routerRegistryImpl) Describe(name string, operation Operation) {
+	fullName := strings.Join(append(append([]string(nil), r.parentNames...), r.versionedName(name)), ".")
+	if !r.inventory.describe(fullName, operation) {
+		panic("cannot describe the unregistered route " + fullName)
+	}
+	if r.versioning != nil && r.version == r.versioning.defaultVersion {
+		// the route served without the version, if any
+		r.inventory.describe(strings.Join(append(append([]string(nil), r.parentNames...), name), "."), operation)
+	}
+}
+
+func (r *routerRegistryImpl) Routes() RouteTable {
+	return r.inventory.snapshot()
+}
+
 func (r *routerRegistryImpl) RegisterNested(
 	name string, path string, params []string,
 	routerGenerator NestedRouter,
 	middlewares []Middleware,
 	methods ...string,
 ) {
-	r.Register(name, path, params, routerGenerator(), middlewares, methods...)
+	handler := routerGenerator()
+	r.register(name, path, params, handler, funcName(handler), middlewares, methods...)
+}
+
+func (r *routerRegistryImpl) RegisterWebSocket(
+	name string, path string, params []string,
+	upgrader eighty.WebSocketUpgrader,
+	handler func(conn eighty.WebSocketConn),
+	middlewares []Middleware,
+) {
+	r.register(name, path, params, func(ctx *fasthttp.RequestCtx) {
+		upgrader.Upgrade(ctx, handler)
+	}, funcName(handler), middlewares, fasthttp.MethodGet)
 }
 
 func (r *routerRegistryImpl) Wrap(name string, path string, middlewares ...Middleware) RouterRegistry {
@@ -78,22 +223,27 @@ func (r *routerRegistryImpl) Wrap(name string, path string, middlewares ...Middl
 		newName, newPath []string
 	)
 	if len(name) > 0 {
-		newName = append(r.parentNames, name)
+		newName = append(append([]string(nil), r.parentNames...), name)
 	} else {
 		newName = r.parentNames
 	}
 	if len(path) > 0 {
-		newPath = append(r.parentPaths, path)
+		newPath = append(append([]string(nil), r.parentPaths...), path)
 	} else {
 		newPath = r.parentPaths
 	}
 	return &routerRegistryImpl{
 		routerContextImpl: r.routerContextImpl,
 		r:                 r.r,
-		middlewares:       append(r.middlewares, middlewares...),
+		middlewares:       append(append([]Middleware(nil), r.middlewares...), middlewares...),
 		name:              strings.Join(newName, "."),
 		parentNames:       newName,
 		parentPaths:       newPath,
+		inventory:         r.inventory,
+		hosts:             r.hosts,
+		host:              r.host,
+		versioning:        r.versioning,
+		version:           r.version,
 	}
 }
 func (r *routerRegistryImpl) String() string { return r.urlFor().String() }
@@ -2,6 +2,7 @@ package routing
 
 import (
 	"errors"
+	"net/url"
 	"path"
 	"sort"
 	"strconv"
@@ -28,6 +29,7 @@ type (
 	UrlFor interface {
 		UrlResolver
 		// Add registers name, parameter, and URL for UrlResolver.
+		// The parameters are derived from the URL pattern, explicitly given params must match them.
 		// If a duplicate name exists, an error is returned instead of registering.
 		Add(urlName, urlAddr string, params ...string) (string, error)
 		// MustAdd registers name, parameter, and URL for UrlResolver.
@@ -39,6 +41,13 @@ type (
 		// MustAddGr registers name, parameter, and URL for UrlResolver with nested group infos.
 		// If a duplicate name exists, it panics.
 		MustAddGr(urlName, urlAddr string, groupNames, groupAddrs []string, params ...string) string
+		// AddHostGr registers name, parameter, and URL for UrlResolver with nested group infos under a host pattern.
+		// The host params lead the params, and the reversed URL is host-qualified like "//acme.example.com/users".
+		// If a duplicate name exists, an error is returned instead of registering.
+		AddHostGr(urlName, host, urlAddr string, groupNames, groupAddrs []string, params ...string) (string, error)
+		// MustAddHostGr registers name, parameter, and URL for UrlResolver with nested group infos under a host pattern.
+		// If a duplicate name exists, it panics.
+		MustAddHostGr(urlName, host, urlAddr string, groupNames, groupAddrs []string, params ...string) string
 		// Clear clears all registered reverse-routing infos.
 		Clear()
 		// String returns summarized info of registered reverse-routing infos.
@@ -47,8 +56,10 @@ type (
 		ToResolver() UrlResolver
 	}
 	routerFragment struct {
-		url    string
-		params []string
+		url      string
+		params   []string
+		host     []routeSegment
+		segments []routeSegment
 	}
 
 	reverseRouter map[string]routerFragment
@@ -114,8 +125,32 @@ func (us *reverseRouter) MustReverseWithParams(urlName string, params []string)
 	return res
 }
 
+// ReverseWithQuery resolves the URL of the name and parameters with the resolver,
+// appending the encoded query parameters and the fragment.
+func ReverseWithQuery(resolver UrlResolver, urlName string, params []string, query url.Values, fragment string) (string, error) {
+	res, err := resolver.ReverseWithParams(urlName, params)
+	if err != nil {
+		return "", err
+	}
+	return appendQuery(res, query, fragment), nil
+}
+
+// ReverseVersion resolves the URL of the name and parameters of the API version with the resolver.
+// An empty version resolves the default version.
+func ReverseVersion(resolver UrlResolver, urlName, version string, params ...string) (string, error) {
+	return resolver.ReverseWithParams(versionedUrlName(urlName, version), params)
+}
+
+// versionedUrlName returns the name of the route of the version, which is registered with the "@version" suffix.
+func versionedUrlName(urlName, version string) string {
+	if len(version) == 0 {
+		return urlName
+	}
+	return urlName + "@" + version
+}
+
 func (us *reverseRouter) MustAdd(urlName, urlAddr string, params ...string) string {
-	addr, err := us.addInternal(urlName, urlAddr, nil, nil, params)
+	addr, err := us.addInternal(urlName, "", urlAddr, nil, nil, params)
 	if err != nil {
 		panic(err)
 	}
@@ -123,11 +158,11 @@ func (us *reverseRouter) MustAdd(urlName, urlAddr string, params ...string) stri
 }
 
 func (us *reverseRouter) Add(urlName, urlAddr string, params ...string) (string, error) {
-	return us.addInternal(urlName, urlAddr, nil, nil, params)
+	return us.addInternal(urlName, "", urlAddr, nil, nil, params)
 }
 
 func (us *reverseRouter) MustAddGr(urlName, urlAddr string, groupNames, groupAddrs []string, params ...string) string {
-	addr, err := us.addInternal(urlName, urlAddr, groupNames, groupAddrs, params)
+	addr, err := us.addInternal(urlName, "", urlAddr, groupNames, groupAddrs, params)
 	if err != nil {
 		panic(err)
 	}
@@ -135,22 +170,49 @@ func (us *reverseRouter) MustAddGr(urlName, urlAddr string, groupNames, groupAdd
 }
 
 func (us *reverseRouter) AddGr(urlName, urlAddr string, groupNames, groupAddrs []string, params ...string) (string, error) {
-	return us.addInternal(urlName, urlAddr, groupNames, groupAddrs, params)
+	return us.addInternal(urlName, "", urlAddr, groupNames, groupAddrs, params)
+}
+
+func (us *reverseRouter) MustAddHostGr(urlName, host, urlAddr string, groupNames, groupAddrs []string, params ...string) string {
+	addr, err := us.addInternal(urlName, host, urlAddr, groupNames, groupAddrs, params)
+	if err != nil {
+		panic(err)
+	}
+	return addr
+}
+
+func (us *reverseRouter) AddHostGr(urlName, host, urlAddr string, groupNames, groupAddrs []string, params ...string) (string, error) {
+	return us.addInternal(urlName, host, urlAddr, groupNames, groupAddrs, params)
 }
 
 func (us *reverseRouter) Reverse(urlName string, params ...string) (string, error) {
 	return us.ReverseWithParams(urlName, params)
 }
 
-func (us reverseRouter) addInternal(urlName, urlAddr string, groupNames, groupAddrs, params []string) (string, error) {
-	if _, ok := us[urlName]; ok {
+func (us reverseRouter) addInternal(urlName, host, urlAddr string, groupNames, groupAddrs, params []string) (string, error) {
+	routeName := strings.Join(append(groupNames, urlName), ".")
+	if _, ok := us[routeName]; ok {
 		return "", errors.New("Url already exists. Try to use .Get() method.")
 	}
-	routeName := strings.Join(append(groupNames, urlName), ".")
 	addr := path.Join(append(groupAddrs, urlAddr)...)
 
-	tmpUrl := routerFragment{addr, params}
-	us[routeName] = tmpUrl
+	segments, err := parseRoutePattern(addr)
+	if err != nil {
+		return "", err
+	}
+	fragment := routerFragment{url: addr, segments: segments}
+	if len(host) > 0 {
+		if fragment.host, _, err = parseHostPattern(host); err != nil {
+			return "", err
+		}
+		fragment.url = "//" + hostPatternOf(fragment.host) + addr
+	}
+	allSegments := append(append([]routeSegment(nil), fragment.host...), segments...)
+	if err = matchRouteParams(allSegments, params); err != nil {
+		return "", errors.New("Bad Url Register: " + err.Error() + " for URL: " + routeName)
+	}
+	fragment.params = routeParamNames(allSegments)
+	us[routeName] = fragment
 	return addr, nil
 }
 
@@ -169,12 +231,22 @@ func (us *reverseRouter) Get(urlName string, params ...string) string {
 }
 
 func (us reverseRouter) ReverseWithParams(urlName string, params []string) (string, error) {
-	if len(params) != len(us[urlName].params) {
+	fragment, ok := us[urlName]
+	if !ok {
+		return "", errors.New("Bad Url Reverse: unknown URL: " + urlName)
+	} else if len(params) != len(fragment.params) {
 		return "", errors.New("Bad Url Reverse: mismatch params for URL: " + urlName)
 	}
-	res := us[urlName].url
-	for i, val := range params {
-		res = strings.Replace(res, us[urlName].params[i], val, 1)
+	hostParams := len(routeParamNames(fragment.host))
+	res, err := buildRoutePath(fragment.segments, params[hostParams:])
+	if err == nil && len(fragment.host) > 0 {
+		var host string
+		if host, err = buildHost(fragment.host, params[:hostParams]); err == nil {
+			res = "//" + host + res
+		}
+	}
+	if err != nil {
+		return "", errors.New("Bad Url Reverse: " + err.Error() + " for URL: " + urlName)
 	}
 	return res, nil
 }
@@ -0,0 +1,82 @@
+package routing
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestReverse(t *testing.T) {
+	urlFor := NewUrlFor()
+	urlFor.MustAdd("user", "/users/{id:[0-9]+}")
+	urlFor.MustAdd("file", "/users/{id}/files/{path:*}")
+	urlFor.MustAdd("search", "/search/{term}")
+	urlFor.MustAdd("post", "/posts/{slug?}")
+	urlFor.MustAdd("pair", "/pairs/{a}/{b}")
+	resolver := urlFor.ToResolver()
+
+	tests := []struct {
+		name    string
+		urlName string
+		params  []string
+		want    string
+		wantErr bool
+	}{
+		{name: "constraint", urlName: "user", params: []string{"42"}, want: "/users/42"},
+		{name: "constraint violated", urlName: "user", params: []string{"me"}, wantErr: true},
+		{name: "catch-all", urlName: "file", params: []string{"7", "/docs/a b.txt"}, want: "/users/7/files/docs/a%20b.txt"},
+		{name: "reserved characters", urlName: "search", params: []string{"a/b?c#d"}, want: "/search/a%2Fb%3Fc%23d"},
+		{name: "empty value", urlName: "search", params: []string{""}, wantErr: true},
+		{name: "optional given", urlName: "post", params: []string{"hello"}, want: "/posts/hello"},
+		{name: "optional dropped", urlName: "post", params: []string{""}, want: "/posts"},
+		{name: "placeholder value", urlName: "pair", params: []string{"{b}", "x"}, want: "/pairs/%7Bb%7D/x"},
+		{name: "params mismatch", urlName: "pair", params: []string{"x"}, wantErr: true},
+		{name: "unknown name", urlName: "missing", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := resolver.ReverseWithParams(test.urlName, test.params)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("reversed %q, want an error", got)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			} else if got != test.want {
+				t.Errorf("reversed %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReverseWithQuery(t *testing.T) {
+	urlFor := NewUrlFor()
+	urlFor.MustAdd("search", "/search/{term}")
+	resolver := urlFor.ToResolver()
+
+	tests := []struct {
+		name     string
+		query    url.Values
+		fragment string
+		want     string
+	}{
+		{name: "bare", want: "/search/go"},
+		{name: "query", query: url.Values{"page": {"2"}, "q": {"a&b=c"}, "tag": {"x", "y"}}, want: "/search/go?page=2&q=a%26b%3Dc&tag=x&tag=y"},
+		{name: "fragment", fragment: "top results", want: "/search/go#top%20results"},
+		{name: "both", query: url.Values{"page": {"2"}}, fragment: "end", want: "/search/go?page=2#end"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ReverseWithQuery(resolver, "search", []string{"go"}, test.query, test.fragment)
+			if err != nil {
+				t.Fatal(err)
+			} else if got != test.want {
+				t.Errorf("reversed %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	if _, err := ReverseWithQuery(resolver, "search", nil, url.Values{"page": {"2"}}, ""); err == nil {
+		t.Error("the missing param was not reported")
+	}
+}
@@ -0,0 +1,77 @@
+package routing
+
+import (
+	"github.com/valyala/fasthttp"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// the key of the metadata of the matched route
+const routeMetaContextKey = "routing.routeMeta"
+
+type (
+	// RouteMeta is the metadata of the matched route, attached to the request before the middlewares run.
+	RouteMeta struct {
+		// Name is the full route name as used by reverse routing, such as "api.users@v2" for a versioned route.
+		Name string
+		// Groups are the names of the groups of the route, outermost first.
+		Groups []string
+		// Host is the host pattern of a host group route, empty otherwise.
+		Host string
+		// Path is the path template, such as "/api/users/{id}".
+		Path string
+		// Version is the API version of a versioned route, empty otherwise.
+		Version string
+		// Tags are the tags attached by RouterRegistry.Tag in the struct tag syntax, such as `auth:"admin"`.
+		Tags reflect.StructTag
+	}
+)
+
+// CurrentRoute returns the metadata of the route that matched the request, nil if no registered route matched.
+func CurrentRoute(ctx *fasthttp.RequestCtx) *RouteMeta {
+	meta, _ := ctx.UserValue(routeMetaContextKey).(*RouteMeta)
+	return meta
+}
+
+// HTTPCurrentRoute returns the metadata of the matched route of a request served through HTTPHandler or HTTPMiddleware.
+func HTTPCurrentRoute(r *http.Request) *RouteMeta {
+	meta, _ := r.Context().Value(routeMetaContextKey).(*RouteMeta)
+	return meta
+}
+
+// withRouteMeta attaches the metadata to the request before the handler runs.
+func withRouteMeta(meta *RouteMeta, handler Router) Router {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctx.SetUserValue(routeMetaContextKey, meta)
+		handler(ctx)
+	}
+}
+
+// tag sets the tags of the route and of its metadata, reporting whether the route exists.
+func (inv *routeInventory) tag(name string, tags reflect.StructTag) bool {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	meta, ok := inv.metas[name]
+	if !ok {
+		return false
+	}
+	meta.Tags = tags
+	for i := range inv.routes {
+		if inv.routes[i].Name == name {
+			inv.routes[i].Tags = string(tags)
+		}
+	}
+	return true
+}
+
+func (r *routerRegistryImpl) Tag(name string, tags string) {
+	fullName := strings.Join(append(append([]string(nil), r.parentNames...), r.versionedName(name)), ".")
+	if !r.inventory.tag(fullName, reflect.StructTag(tags)) {
+		panic("cannot tag the unregistered route " + fullName)
+	}
+	if r.versioning != nil && r.version == r.versioning.defaultVersion {
+		// the route served without the version, if any
+		r.inventory.tag(strings.Join(append(append([]string(nil), r.parentNames...), name), "."), reflect.StructTag(tags))
+	}
+}
@@ -0,0 +1,157 @@
+package routing
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+type (
+	// routeSegment is a part of a route pattern, either a literal text or a parameter.
+	routeSegment struct {
+		literal  string
+		param    string
+		pattern  string
+		matcher  *regexp.Regexp
+		optional bool
+		catchAll bool
+	}
+)
+
+// parseRoutePattern splits a fasthttp/router pattern such as "/users/{id:[0-9]+}/files/{path:*}"
+// into its literal and parameter segments.
+func parseRoutePattern(pattern string) (segments []routeSegment, err error) {
+	for len(pattern) > 0 {
+		start := strings.IndexByte(pattern, '{')
+		if start < 0 {
+			segments = append(segments, routeSegment{literal: pattern})
+			break
+		} else if start > 0 {
+			segments = append(segments, routeSegment{literal: pattern[:start]})
+		}
+
+		// regular expressions may contain braces, find the matching one
+		depth, end := 0, -1
+		for i := start; i < len(pattern) && end < 0; i++ {
+			switch pattern[i] {
+			case '{':
+				depth++
+			case '}':
+				if depth--; depth == 0 {
+					end = i
+				}
+			}
+		}
+		if end < 0 {
+			return nil, errors.New("unclosed parameter in route pattern: " + pattern)
+		}
+
+		segment := routeSegment{param: pattern[start+1 : end]}
+		if name, regex, ok := strings.Cut(segment.param, ":"); ok {
+			segment.param = name
+			if regex == "*" {
+				segment.catchAll = true
+			} else if segment.matcher, err = regexp.Compile("^(?:" + regex + ")$"); err != nil {
+				return nil, err
+			} else {
+				segment.pattern = regex
+			}
+		}
+		if strings.HasSuffix(segment.param, "?") {
+			segment.param, segment.optional = strings.TrimSuffix(segment.param, "?"), true
+		}
+		if len(segment.param) == 0 {
+			return nil, errors.New("unnamed parameter in route pattern: " + pattern)
+		} else if segment.catchAll && end != len(pattern)-1 {
+			return nil, errors.New("catch-all parameter must be the last segment: " + pattern)
+		}
+		segments = append(segments, segment)
+		pattern = pattern[end+1:]
+	}
+	return
+}
+
+// routeParamNames returns the parameter names of the segments in order.
+func routeParamNames(segments []routeSegment) (names []string) {
+	for _, segment := range segments {
+		if len(segment.param) > 0 {
+			names = append(names, segment.param)
+		}
+	}
+	return
+}
+
+// matchRouteParams checks the explicitly supplied params against the names derived from the pattern.
+// A param is either the bare name or the placeholder text as written in the pattern.
+func matchRouteParams(segments []routeSegment, params []string) error {
+	names := routeParamNames(segments)
+	if len(params) == 0 {
+		return nil
+	} else if len(params) != len(names) {
+		return errors.New("route params " + strings.Join(params, ",") + " do not match the pattern params " + strings.Join(names, ","))
+	}
+	for i, param := range params {
+		if strings.HasPrefix(param, "{") && strings.HasSuffix(param, "}") {
+			param, _, _ = strings.Cut(param[1:len(param)-1], ":")
+			param = strings.TrimSuffix(param, "?")
+		}
+		if param != names[i] {
+			return errors.New("route param " + params[i] + " does not match the pattern param " + names[i])
+		}
+	}
+	return nil
+}
+
+// buildRoutePath substitutes the params into the segments, percent-escaping the values.
+// The values are checked against the regex constraints, a catch-all value keeps its slashes,
+// and an empty optional value drops the parameter with its leading slash.
+func buildRoutePath(segments []routeSegment, params []string) (string, error) {
+	var (
+		builder  strings.Builder
+		paramIdx int
+	)
+	for _, segment := range segments {
+		if len(segment.param) == 0 {
+			builder.WriteString(segment.literal)
+			continue
+		}
+		value := params[paramIdx]
+		paramIdx++
+
+		switch {
+		case len(value) == 0 && segment.optional:
+			if built := builder.String(); len(built) > 1 && strings.HasSuffix(built, "/") {
+				builder.Reset()
+				builder.WriteString(strings.TrimSuffix(built, "/"))
+			}
+		case segment.catchAll:
+			if strings.HasSuffix(builder.String(), "/") {
+				value = strings.TrimPrefix(value, "/")
+			}
+			parts := strings.Split(value, "/")
+			for i, part := range parts {
+				parts[i] = url.PathEscape(part)
+			}
+			builder.WriteString(strings.Join(parts, "/"))
+		case len(value) == 0:
+			return "", errors.New("empty value for param " + segment.param)
+		case segment.matcher != nil && !segment.matcher.MatchString(value):
+			return "", errors.New("value " + value + " does not match the constraint " + segment.pattern + " of param " + segment.param)
+		default:
+			builder.WriteString(url.PathEscape(value))
+		}
+	}
+	return builder.String(), nil
+}
+
+// appendQuery appends the encoded query and fragment to the path.
+func appendQuery(path string, query url.Values, fragment string) string {
+	if encoded := query.Encode(); len(encoded) > 0 {
+		path += "?" + encoded
+	}
+	if len(fragment) > 0 {
+		path += "#" + (&url.URL{Fragment: fragment}).EscapedFragment()
+	}
+	return path
+}
@@ -0,0 +1,87 @@
+package routing
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestParseRoutePattern(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		wantParams []string
+		wantErr    string
+	}{
+		{pattern: "/users"},
+		{pattern: "/users/{id}", wantParams: []string{"id"}},
+		{pattern: "/users/{id:[0-9]+}/files/{path:*}", wantParams: []string{"id", "path"}},
+		{pattern: "/posts/{year:[0-9]{4}}/{slug?}", wantParams: []string{"year", "slug"}},
+		{pattern: "/users/{id", wantErr: "unclosed"},
+		{pattern: "/users/{}", wantErr: "unnamed"},
+		{pattern: "/files/{path:*}/raw", wantErr: "catch-all"},
+		{pattern: "/users/{id:[0-9+}", wantErr: "missing closing ]"},
+	}
+	for _, test := range tests {
+		t.Run(test.pattern, func(t *testing.T) {
+			segments, err := parseRoutePattern(test.pattern)
+			if len(test.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("got %v, want an error about %q", err, test.wantErr)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if params := routeParamNames(segments); !reflect.DeepEqual(params, test.wantParams) {
+				t.Errorf("params %v, want %v", params, test.wantParams)
+			}
+		})
+	}
+}
+
+func TestUrlForAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		params  []string
+		wantErr bool
+	}{
+		{name: "derived", path: "/users/{id:[0-9]+}/files/{path:*}"},
+		{name: "named", path: "/users/{id:[0-9]+}/files/{path:*}", params: []string{"id", "path"}},
+		{name: "placeholders", path: "/users/{id:[0-9]+}/{slug?}", params: []string{"{id:[0-9]+}", "{slug?}"}},
+		{name: "missing", path: "/users/{id}/files/{path:*}", params: []string{"id"}, wantErr: true},
+		{name: "misordered", path: "/users/{id}/files/{path:*}", params: []string{"path", "id"}, wantErr: true},
+		{name: "unknown", path: "/users/{id}", params: []string{"user"}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewUrlFor().Add(test.name, test.path, test.params...)
+			if (err != nil) != test.wantErr {
+				t.Errorf("got %v, want error %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestDuplicateRouteNames(t *testing.T) {
+	urlFor := NewUrlFor()
+	urlFor.MustAddGr("users", "/users", []string{"api"}, []string{"/api"})
+	if _, err := urlFor.AddGr("users", "/people", []string{"api"}, []string{"/api"}); err == nil {
+		t.Error("the duplicate name in the group was registered")
+	} else if _, err = urlFor.Add("api.users", "/people"); err == nil {
+		t.Error("the duplicate full name was registered")
+	} else if _, err = urlFor.AddGr("users", "/users", []string{"admin"}, []string{"/admin"}); err != nil {
+		t.Errorf("the name in another group was rejected: %v", err)
+	}
+
+	registry := NewRouterContext("", NewUrlFor()).BuildRouter(nil)
+	registry.Register("users", "/users", nil, writeBody("users"), nil, fasthttp.MethodGet)
+	defer func() {
+		if recovered := recover(); recovered == nil {
+			t.Error("registering the duplicate name did not panic")
+		}
+	}()
+	registry.Register("users", "/people", nil, writeBody("people"), nil, fasthttp.MethodGet)
+}
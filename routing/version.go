@@ -0,0 +1,200 @@
+package routing
+
+import (
+	"github.com/spi-ca/eighty"
+	"github.com/spi-ca/misc/strutil"
+	"github.com/valyala/fasthttp"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	versionByPath versionSource = iota
+	versionByHeader
+	versionByAccept
+)
+
+type (
+	versionSource int
+
+	// VersionScheme selects where the API version of a request is read from.
+	VersionScheme struct {
+		source versionSource
+		name   string
+	}
+
+	// VersionedRegistry is a group that serves the versions of its routes side by side.
+	VersionedRegistry interface {
+		// Version returns the group of the routes of the version.
+		// The routes are reversed by their name with the version, see ReverseVersion,
+		// and the routes of the default version are also reversed by their plain name.
+		// A non-zero deprecation marks the version deprecated, its responses then carry the Deprecation header,
+		// and the Sunset header if sunset is non-zero too.
+		Version(version string, deprecation, sunset time.Time) RouterRegistry
+	}
+
+	apiVersioning struct {
+		group          *routerRegistryImpl
+		scheme         VersionScheme
+		defaultVersion string
+		dispatchers    map[string]*versionDispatcher
+		// unknownVersion renders the error of an unserved version through the group middlewares
+		unknownVersion Router
+	}
+
+	// versionDispatcher serves a method and path shared by the versions selected by header or media type.
+	versionDispatcher struct {
+		versioning *apiVersioning
+		handlers   map[string]Router
+	}
+)
+
+// VersionByPath selects the version by the path segment following the group path, such as "/api/v2/users".
+// Requests without the segment are served by the default version.
+func VersionByPath() VersionScheme { return VersionScheme{source: versionByPath} }
+
+// VersionByHeader selects the version by the value of the request header, such as "X-API-Version".
+func VersionByHeader(header string) VersionScheme {
+	return VersionScheme{source: versionByHeader, name: header}
+}
+
+// VersionByAccept selects the version by the media type parameter of the Accept header,
+// such as "application/vnd.x+json;version=2" with param "version".
+func VersionByAccept(param string) VersionScheme {
+	return VersionScheme{source: versionByAccept, name: param}
+}
+
+// requested returns the version the request asks for, empty if none.
+func (s VersionScheme) requested(ctx *fasthttp.RequestCtx) string {
+	switch s.source {
+	case versionByHeader:
+		return strings.TrimSpace(strutil.B2S(ctx.Request.Header.Peek(s.name)))
+	case versionByAccept:
+		for _, mediaRange := range strings.Split(strutil.B2S(ctx.Request.Header.Peek(eighty.AcceptHeader)), ",") {
+			params := strings.Split(mediaRange, ";")
+			for _, param := range params[1:] {
+				if key, value, ok := strings.Cut(param, "="); ok && strings.EqualFold(strings.TrimSpace(key), s.name) {
+					return strings.Trim(strings.TrimSpace(value), `"`)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// varyOn merges the request header name into the Vary header.
+func varyOn(header *fasthttp.ResponseHeader, name string) {
+	current := strutil.B2S(header.Peek(eighty.VaryHeader))
+	for _, existing := range strings.Split(current, ",") {
+		if existing = strings.TrimSpace(existing); strings.EqualFold(existing, name) || existing == "*" {
+			return
+		}
+	}
+	if len(current) > 0 {
+		header.Set(eighty.VaryHeader, current+", "+name)
+	} else {
+		header.Set(eighty.VaryHeader, name)
+	}
+}
+
+// deprecationMiddleware announces the deprecation and the sunset of a version.
+func deprecationMiddleware(deprecation, sunset time.Time) Middleware {
+	deprecationValue := "@" + strconv.FormatInt(deprecation.Unix(), 10)
+	var sunsetValue string
+	if !sunset.IsZero() {
+		sunsetValue = sunset.UTC().Format(http.TimeFormat)
+	}
+	return func(next Router) Router {
+		return func(ctx *fasthttp.RequestCtx) {
+			ctx.Response.Header.Set(eighty.DeprecationHeader, deprecationValue)
+			if len(sunsetValue) > 0 {
+				ctx.Response.Header.Set(eighty.SunsetHeader, sunsetValue)
+			}
+			next(ctx)
+		}
+	}
+}
+
+func (v *apiVersioning) Version(version string, deprecation, sunset time.Time) RouterRegistry {
+	var (
+		path        string
+		middlewares []Middleware
+	)
+	if v.scheme.source == versionByPath {
+		path = "/" + version
+	}
+	if !deprecation.IsZero() {
+		middlewares = append(middlewares, deprecationMiddleware(deprecation, sunset))
+	}
+	child := v.group.Wrap("", path, middlewares...).(*routerRegistryImpl)
+	child.versioning, child.version = v, version
+	return child
+}
+
+// unversionedPaths removes the version segment from the group paths of a version.
+func (v *apiVersioning) unversionedPaths(paths []string) []string {
+	if v.scheme.source != versionByPath {
+		return paths
+	}
+	idx := len(v.group.parentPaths)
+	return append(append([]string(nil), paths[:idx]...), paths[idx+1:]...)
+}
+
+// handle returns the handler of the version to register on the router, reporting whether it needs registering.
+// The versions selected by header or media type share a dispatcher, which is registered once.
+func (v *apiVersioning) handle(version, method, path string, handler Router) (Router, bool) {
+	if v.scheme.source == versionByPath {
+		return handler, true
+	}
+	key := method + " " + path
+	if dispatcher, ok := v.dispatchers[key]; ok {
+		dispatcher.handlers[version] = handler
+		return nil, false
+	}
+	dispatcher := &versionDispatcher{versioning: v, handlers: map[string]Router{version: handler}}
+	v.dispatchers[key] = dispatcher
+	return dispatcher.serve, true
+}
+
+func (d *versionDispatcher) serve(ctx *fasthttp.RequestCtx) {
+	scheme := d.versioning.scheme
+	if scheme.source == versionByAccept {
+		varyOn(&ctx.Response.Header, eighty.AcceptHeader)
+	} else {
+		varyOn(&ctx.Response.Header, scheme.name)
+	}
+
+	version := scheme.requested(ctx)
+	if len(version) == 0 {
+		version = d.versioning.defaultVersion
+	}
+	if handler, ok := d.handlers[version]; ok {
+		handler(ctx)
+	} else {
+		d.versioning.unknownVersion(ctx)
+	}
+}
+
+func (r *routerRegistryImpl) WrapVersions(
+	name string, path string,
+	scheme VersionScheme, defaultVersion string,
+	middlewares ...Middleware,
+) VersionedRegistry {
+	if r.versioning != nil {
+		panic("cannot wrap the versioned group " + name + " under a version")
+	}
+	group := r.Wrap(name, path, middlewares...).(*routerRegistryImpl)
+	unknownVersion := eighty.HandledErrorNotFound
+	if scheme.source == versionByAccept {
+		unknownVersion = eighty.HandledErrorNotAcceptable
+	}
+	return &apiVersioning{
+		group:          group,
+		scheme:         scheme,
+		defaultVersion: defaultVersion,
+		dispatchers:    make(map[string]*versionDispatcher),
+		unknownVersion: JustCode(unknownVersion, group.middlewares...),
+	}
+}
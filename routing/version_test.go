@@ -0,0 +1,124 @@
+package routing
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/spi-ca/eighty"
+	"github.com/valyala/fasthttp"
+)
+
+// renderStatus renders the HandledError a handler panics with as its status code.
+func renderStatus(next Router) Router {
+	return func(ctx *fasthttp.RequestCtx) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				handled, _ := eighty.WrapHandledError(recovered)
+				ctx.SetStatusCode(handled.StatusCode())
+			}
+		}()
+		next(ctx)
+	}
+}
+
+func versionedRegistry(scheme VersionScheme, deprecation, sunset time.Time) (RouterContext, RouterRegistry) {
+	routerCtx := NewRouterContext("", NewUrlFor())
+	registry := routerCtx.BuildRouter(renderStatus)
+	versions := registry.WrapVersions("api", "/api", scheme, "1", renderStatus)
+	versions.Version("1", deprecation, sunset).Register("items", "/items", nil, writeBody("v1 items"), nil, fasthttp.MethodGet)
+	versions.Version("2", time.Time{}, time.Time{}).Register("items", "/items", nil, writeBody("v2 items"), nil, fasthttp.MethodGet)
+	return routerCtx, registry
+}
+
+func TestVersionNegotiation(t *testing.T) {
+	tests := []struct {
+		name       string
+		scheme     VersionScheme
+		uri        string
+		headers    []string
+		wantStatus int
+		wantBody   string
+		wantVary   string
+	}{
+		{name: "path", scheme: VersionByPath(), uri: "/api/2/items", wantStatus: fasthttp.StatusOK, wantBody: "v2 items"},
+		{name: "path default", scheme: VersionByPath(), uri: "/api/items", wantStatus: fasthttp.StatusOK, wantBody: "v1 items"},
+		{name: "path explicit default", scheme: VersionByPath(), uri: "/api/1/items", wantStatus: fasthttp.StatusOK, wantBody: "v1 items"},
+		{name: "path unknown", scheme: VersionByPath(), uri: "/api/3/items", wantStatus: fasthttp.StatusNotFound},
+		{name: "header", scheme: VersionByHeader("X-API-Version"), uri: "/api/items", headers: []string{"X-API-Version", "2"},
+			wantStatus: fasthttp.StatusOK, wantBody: "v2 items", wantVary: "X-API-Version"},
+		{name: "header default", scheme: VersionByHeader("X-API-Version"), uri: "/api/items",
+			wantStatus: fasthttp.StatusOK, wantBody: "v1 items", wantVary: "X-API-Version"},
+		{name: "header unknown", scheme: VersionByHeader("X-API-Version"), uri: "/api/items", headers: []string{"X-API-Version", "3"},
+			wantStatus: fasthttp.StatusNotFound, wantVary: "X-API-Version"},
+		{name: "accept", scheme: VersionByAccept("version"), uri: "/api/items", headers: []string{eighty.AcceptHeader, "text/html, application/vnd.x+json; version=2"},
+			wantStatus: fasthttp.StatusOK, wantBody: "v2 items", wantVary: eighty.AcceptHeader},
+		{name: "accept quoted", scheme: VersionByAccept("version"), uri: "/api/items", headers: []string{eighty.AcceptHeader, `application/vnd.x+json;Version="1"`},
+			wantStatus: fasthttp.StatusOK, wantBody: "v1 items", wantVary: eighty.AcceptHeader},
+		{name: "accept default", scheme: VersionByAccept("version"), uri: "/api/items", headers: []string{eighty.AcceptHeader, "application/json"},
+			wantStatus: fasthttp.StatusOK, wantBody: "v1 items", wantVary: eighty.AcceptHeader},
+		{name: "accept unknown", scheme: VersionByAccept("version"), uri: "/api/items", headers: []string{eighty.AcceptHeader, "application/vnd.x+json;version=3"},
+			wantStatus: fasthttp.StatusNotAcceptable, wantVary: eighty.AcceptHeader},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, registry := versionedRegistry(test.scheme, time.Time{}, time.Time{})
+			ctx := serve(t, registry.Handler, fasthttp.MethodGet, test.uri, test.headers...)
+			if status := ctx.Response.StatusCode(); status != test.wantStatus {
+				t.Fatalf("status %d, want %d", status, test.wantStatus)
+			} else if body := string(ctx.Response.Body()); len(test.wantBody) > 0 && body != test.wantBody {
+				t.Errorf("body %q, want %q", body, test.wantBody)
+			}
+			if vary := string(ctx.Response.Header.Peek(eighty.VaryHeader)); vary != test.wantVary {
+				t.Errorf("Vary %q, want %q", vary, test.wantVary)
+			}
+		})
+	}
+}
+
+func TestVersionDeprecation(t *testing.T) {
+	deprecation := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2025, time.June, 30, 12, 0, 0, 0, time.FixedZone("KST", 9*60*60))
+	_, registry := versionedRegistry(VersionByHeader("X-API-Version"), deprecation, sunset)
+
+	ctx := serve(t, registry.Handler, fasthttp.MethodGet, "/api/items")
+	if value := string(ctx.Response.Header.Peek(eighty.DeprecationHeader)); value != "@1704067200" {
+		t.Errorf("Deprecation %q", value)
+	} else if value = string(ctx.Response.Header.Peek(eighty.SunsetHeader)); value != sunset.UTC().Format(http.TimeFormat) {
+		t.Errorf("Sunset %q", value)
+	}
+	ctx = serve(t, registry.Handler, fasthttp.MethodGet, "/api/items", "X-API-Version", "2")
+	if len(ctx.Response.Header.Peek(eighty.DeprecationHeader)) > 0 || len(ctx.Response.Header.Peek(eighty.SunsetHeader)) > 0 {
+		t.Error("the current version is announced deprecated")
+	}
+}
+
+func TestReverseVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		scheme  VersionScheme
+		version string
+		want    string
+	}{
+		{name: "path", scheme: VersionByPath(), version: "2", want: "/api/2/items"},
+		{name: "path default", scheme: VersionByPath(), want: "/api/items"},
+		{name: "path explicit default", scheme: VersionByPath(), version: "1", want: "/api/1/items"},
+		{name: "header", scheme: VersionByHeader("X-API-Version"), version: "2", want: "/api/items"},
+		{name: "header default", scheme: VersionByHeader("X-API-Version"), want: "/api/items"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			routerCtx, _ := versionedRegistry(test.scheme, time.Time{}, time.Time{})
+			if url, err := ReverseVersion(routerCtx.UrlResolver(), "api.items", test.version); err != nil {
+				t.Fatal(err)
+			} else if url != test.want {
+				t.Errorf("reversed %q, want %q", url, test.want)
+			}
+		})
+	}
+
+	routerCtx, _ := versionedRegistry(VersionByPath(), time.Time{}, time.Time{})
+	if _, err := ReverseVersion(routerCtx.UrlResolver(), "api.items", "3"); err == nil {
+		t.Error("the unknown version was reversed")
+	}
+}
@@ -0,0 +1,132 @@
+package eighty
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Collection of predefined HMAC request signing header names.
+const (
+	SignatureHeader     = "Signature"
+	DigestHeader        = "Digest"
+	SignatureDateHeader = "X-Signature-Date"
+)
+
+const (
+	signatureAlgorithm    = "hmac-sha256"
+	signatureRequestLine  = "(request-target)"
+	digestAlgorithmPrefix = "SHA-256="
+)
+
+var (
+	// ErrMalformedSignature is returned when the Signature header cannot be parsed.
+	ErrMalformedSignature = errors.New("malformed signature header")
+)
+
+// SignatureHeaders returns the lower-cased header names that are always signed, followed by the extra ones.
+func SignatureHeaders(extra ...string) []string {
+	headers := []string{strings.ToLower(SignatureDateHeader), strings.ToLower(DigestHeader)}
+	for _, name := range extra {
+		headers = append(headers, strings.ToLower(name))
+	}
+	return headers
+}
+
+// BodyDigest returns the Digest header value of the request body.
+func BodyDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return digestAlgorithmPrefix + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// SignatureBase builds the canonical string over the method, the request URI and the signed header values.
+func SignatureBase(method, requestURI string, signedHeaders []string, headerValue func(name string) string) string {
+	var builder strings.Builder
+	builder.WriteString(signatureRequestLine)
+	builder.WriteString(": ")
+	builder.WriteString(strings.ToLower(method))
+	builder.WriteByte(' ')
+	builder.WriteString(requestURI)
+	for _, name := range signedHeaders {
+		builder.WriteByte('\n')
+		builder.WriteString(name)
+		builder.WriteString(": ")
+		builder.WriteString(strings.TrimSpace(headerValue(name)))
+	}
+	return builder.String()
+}
+
+// SignHMAC returns the base64 encoded HMAC-SHA256 signature of the signature base.
+func SignHMAC(secret []byte, base string) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(base))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// FormatSignature returns the Signature header value.
+func FormatSignature(keyID string, signedHeaders []string, signature string) string {
+	return `keyId=` + strconv.Quote(keyID) +
+		`,algorithm="` + signatureAlgorithm +
+		`",headers=` + strconv.Quote(strings.Join(signedHeaders, " ")) +
+		`,signature=` + strconv.Quote(signature)
+}
+
+// ParseSignature parses the Signature header value made by FormatSignature.
+// The parameter values are quoted strings, which may contain commas, or plain tokens.
+func ParseSignature(value string) (keyID string, signedHeaders []string, signature string, err error) {
+	for rest := strings.TrimSpace(value); len(rest) > 0; {
+		name, remainder, found := strings.Cut(rest, "=")
+		if !found {
+			err = ErrMalformedSignature
+			return
+		}
+		name, remainder = strings.TrimSpace(name), strings.TrimLeft(remainder, " \t")
+
+		var unquoted string
+		if strings.HasPrefix(remainder, `"`) {
+			var quoted string
+			if quoted, err = strconv.QuotedPrefix(remainder); err != nil {
+				err = ErrMalformedSignature
+				return
+			} else if unquoted, err = strconv.Unquote(quoted); err != nil {
+				err = ErrMalformedSignature
+				return
+			}
+			remainder = remainder[len(quoted):]
+		} else {
+			idx := strings.IndexByte(remainder, ',')
+			if idx < 0 {
+				idx = len(remainder)
+			}
+			unquoted, remainder = strings.TrimSpace(remainder[:idx]), remainder[idx:]
+		}
+
+		// the parameter ends at a comma or at the end of the value
+		if remainder = strings.TrimLeft(remainder, " \t"); len(remainder) > 0 && remainder[0] != ',' {
+			err = ErrMalformedSignature
+			return
+		}
+		rest = strings.TrimSpace(strings.TrimPrefix(remainder, ","))
+
+		switch name {
+		case "keyId":
+			keyID = unquoted
+		case "algorithm":
+			if unquoted != signatureAlgorithm {
+				err = ErrMalformedSignature
+				return
+			}
+		case "headers":
+			signedHeaders = strings.Fields(unquoted)
+		case "signature":
+			signature = unquoted
+		}
+	}
+	if len(keyID) == 0 || len(signature) == 0 {
+		err = ErrMalformedSignature
+	}
+	return
+}
@@ -0,0 +1,62 @@
+package eighty
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSignature(t *testing.T) {
+	tests := []struct {
+		name              string
+		value             string
+		wantKeyID         string
+		wantSignedHeaders []string
+		wantSignature     string
+		wantErr           bool
+	}{
+		{
+			name:              "formatted",
+			value:             FormatSignature("key-1", []string{"x-signature-date", "digest", "host"}, "c2lnbmF0dXJl"),
+			wantKeyID:         "key-1",
+			wantSignedHeaders: []string{"x-signature-date", "digest", "host"},
+			wantSignature:     "c2lnbmF0dXJl",
+		},
+		{
+			name:              "comma and quote in a quoted value",
+			value:             FormatSignature(`team "a", ops`, nil, "c2lnbmF0dXJl"),
+			wantSignedHeaders: []string{},
+			wantKeyID:         `team "a", ops`,
+			wantSignature:     "c2lnbmF0dXJl",
+		},
+		{
+			name:              "spaces and tokens",
+			value:             ` keyId = "key-1" , algorithm=hmac-sha256,headers="digest" , signature="c2ln"`,
+			wantKeyID:         "key-1",
+			wantSignedHeaders: []string{"digest"},
+			wantSignature:     "c2ln",
+		},
+		{name: "empty", value: "", wantErr: true},
+		{name: "missing signature", value: `keyId="key-1"`, wantErr: true},
+		{name: "unterminated quote", value: `keyId="key-1,signature="c2ln"`, wantErr: true},
+		{name: "text after a quoted value", value: `keyId="key-1"x,signature="c2ln"`, wantErr: true},
+		{name: "missing equals sign", value: `keyId="key-1",signature`, wantErr: true},
+		{name: "other algorithm", value: `keyId="key-1",algorithm="rsa-sha256",signature="c2ln"`, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keyID, signedHeaders, signature, err := ParseSignature(test.value)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("parsed %q without error", test.value)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if keyID != test.wantKeyID || signature != test.wantSignature || !reflect.DeepEqual(signedHeaders, test.wantSignedHeaders) {
+				t.Errorf("got (%q, %q, %q), want (%q, %q, %q)",
+					keyID, signedHeaders, signature, test.wantKeyID, test.wantSignedHeaders, test.wantSignature)
+			}
+		})
+	}
+}
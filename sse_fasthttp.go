@@ -0,0 +1,168 @@
+package eighty
+
+import (
+	"bufio"
+	"errors"
+	"github.com/valyala/fasthttp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrEventStreamClosed is returned when an event is written after the stream ended or the client went away.
+	ErrEventStreamClosed = errors.New("event stream closed")
+
+	eventFieldReplacer = strings.NewReplacer("\r\n", "", "\r", "", "\n", "", "\x00", "")
+	eventDataReplacer  = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+)
+
+type (
+	// ServerSentEvent is an event of the text/event-stream.
+	ServerSentEvent struct {
+		// ID is the event id that the client sends back as Last-Event-ID on reconnect.
+		ID string
+		// Event is the event type, "message" if empty.
+		Event string
+		// Data is the event payload, it can span multiple lines.
+		Data string
+		// Retry is the reconnection delay advertised to the client, not sent if zero.
+		Retry time.Duration
+	}
+
+	// EventStreamWriter writes Server-Sent Events to a client. It is safe for concurrent use.
+	EventStreamWriter interface {
+		// Send writes and flushes the event. An event without Data only updates the id and the retry of the client.
+		Send(event ServerSentEvent) error
+		// Comment writes and flushes a comment line, which the client ignores.
+		Comment(text string) error
+		// LastEventID returns the Last-Event-ID header of the reconnecting client.
+		LastEventID() string
+		// Done returns a channel that is closed when the client disconnects or the server shuts down.
+		Done() <-chan struct{}
+	}
+
+	eventStreamWriterImpl struct {
+		lock        sync.Mutex
+		w           *bufio.Writer
+		lastEventID string
+		closed      bool
+		done        chan struct{}
+	}
+)
+
+// ServeEventStreamFasthttp responds with a text/event-stream driven by stream.
+// stream runs after the handler returns, so it must not touch the fasthttp.RequestCtx;
+// the stream ends when stream returns.
+// If heartbeat is positive, a comment is sent at that interval to keep intermediaries from closing the connection,
+// which also detects the client disconnect while no event is sent.
+func ServeEventStreamFasthttp(ctx *fasthttp.RequestCtx, heartbeat time.Duration, stream func(w EventStreamWriter)) {
+	ctx.SetContentType(EventStreamContentType[0])
+	ctx.Response.Header.Set(CacheControlHeader, "no-cache")
+	// disable the response buffering of nginx
+	ctx.Response.Header.Set("X-Accel-Buffering", "no")
+	ctx.SetStatusCode(fasthttp.StatusOK)
+
+	lastEventID := string(ctx.Request.Header.Peek(LastEventIDHeader))
+	shutdown := ctx.Done()
+	ctx.SetBodyStreamWriter(func(bw *bufio.Writer) {
+		w := &eventStreamWriterImpl{
+			w:           bw,
+			lastEventID: lastEventID,
+			done:        make(chan struct{}),
+		}
+		defer w.close()
+
+		// send the headers right away
+		if w.write(nil) != nil {
+			return
+		}
+		go func() {
+			var ticks <-chan time.Time
+			if heartbeat > 0 {
+				ticker := time.NewTicker(heartbeat)
+				defer ticker.Stop()
+				ticks = ticker.C
+			}
+			for {
+				select {
+				case <-ticks:
+					_ = w.write([]byte(":\n\n"))
+				case <-shutdown:
+					w.close()
+					return
+				case <-w.done:
+					return
+				}
+			}
+		}()
+		stream(w)
+	})
+}
+
+// write writes the chunk and flushes it, closing the stream on failure.
+func (w *eventStreamWriterImpl) write(chunk []byte) (err error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return ErrEventStreamClosed
+	}
+	if _, err = w.w.Write(chunk); err == nil {
+		err = w.w.Flush()
+	}
+	if err != nil {
+		w.closeLocked()
+	}
+	return
+}
+
+func (w *eventStreamWriterImpl) close() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.closeLocked()
+}
+
+func (w *eventStreamWriterImpl) closeLocked() {
+	if !w.closed {
+		w.closed = true
+		close(w.done)
+	}
+}
+
+func (w *eventStreamWriterImpl) Send(event ServerSentEvent) error {
+	var buf strings.Builder
+	if len(event.ID) > 0 {
+		buf.WriteString("id: " + eventFieldReplacer.Replace(event.ID) + "\n")
+	}
+	if len(event.Event) > 0 {
+		buf.WriteString("event: " + eventFieldReplacer.Replace(event.Event) + "\n")
+	}
+	if event.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(event.Retry.Milliseconds(), 10) + "\n")
+	}
+	if len(event.Data) > 0 {
+		for _, line := range strings.Split(eventDataReplacer.Replace(event.Data), "\n") {
+			buf.WriteString("data: " + line + "\n")
+		}
+	}
+	buf.WriteByte('\n')
+	return w.write([]byte(buf.String()))
+}
+
+func (w *eventStreamWriterImpl) Comment(text string) error {
+	var buf strings.Builder
+	for _, line := range strings.Split(eventDataReplacer.Replace(text), "\n") {
+		buf.WriteString(": " + line + "\n")
+	}
+	buf.WriteByte('\n')
+	return w.write([]byte(buf.String()))
+}
+
+func (w *eventStreamWriterImpl) LastEventID() string {
+	return w.lastEventID
+}
+
+func (w *eventStreamWriterImpl) Done() <-chan struct{} {
+	return w.done
+}
@@ -65,8 +65,12 @@ func HandledErrorCodeOf(value int) (HandledError, bool) {
 		return HandledErrorMethodNotAllowed, true
 	case int(HandledErrorNotAcceptable):
 		return HandledErrorNotAcceptable, true
+	case int(HandledErrorRequestTimeout):
+		return HandledErrorRequestTimeout, true
 	case int(HandledErrorGone):
 		return HandledErrorGone, true
+	case int(HandledErrorTooManyRequests):
+		return HandledErrorTooManyRequests, true
 	case int(HandledErrorNotImplemented):
 		return HandledErrorNotImplemented, true
 	case int(HandledErrorBadGateway):
@@ -0,0 +1,511 @@
+package eighty
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"github.com/spi-ca/misc/strutil"
+	"github.com/valyala/fasthttp"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Collection of WebSocket handshake header names.
+const (
+	UpgradeHeader              = "Upgrade"
+	ConnectionHeader           = "Connection"
+	SecWebSocketKeyHeader      = "Sec-WebSocket-Key"
+	SecWebSocketVersionHeader  = "Sec-WebSocket-Version"
+	SecWebSocketAcceptHeader   = "Sec-WebSocket-Accept"
+	SecWebSocketProtocolHeader = "Sec-WebSocket-Protocol"
+	OriginHeader               = "Origin"
+)
+
+const (
+	// DefaultWebSocketMessageLimit is the message size limit of NewWebSocketUpgrader if not specified.
+	DefaultWebSocketMessageLimit = 1 << 20
+
+	websocketGUID         = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	websocketVersion      = "13"
+	websocketWriteTimeout = 10 * time.Second
+	websocketCloseTimeout = 5 * time.Second
+)
+
+// Collection of WebSocket message types.
+const (
+	WebSocketTextMessage   WebSocketMessageType = 1
+	WebSocketBinaryMessage WebSocketMessageType = 2
+
+	websocketContinuation byte = 0
+	websocketClose        byte = 8
+	websocketPing         byte = 9
+	websocketPong         byte = 10
+)
+
+// Collection of WebSocket close codes defined by RFC 6455.
+const (
+	WebSocketCloseNormal          WebSocketCloseCode = 1000
+	WebSocketCloseGoingAway       WebSocketCloseCode = 1001
+	WebSocketCloseProtocolError   WebSocketCloseCode = 1002
+	WebSocketCloseUnsupportedData WebSocketCloseCode = 1003
+	WebSocketCloseNoStatus        WebSocketCloseCode = 1005
+	WebSocketCloseAbnormal        WebSocketCloseCode = 1006
+	WebSocketCloseInvalidPayload  WebSocketCloseCode = 1007
+	WebSocketClosePolicyViolation WebSocketCloseCode = 1008
+	WebSocketCloseMessageTooBig   WebSocketCloseCode = 1009
+	WebSocketCloseInternalError   WebSocketCloseCode = 1011
+)
+
+var (
+	// ErrWebSocketClosed is returned when a message is written after the close frame was sent.
+	ErrWebSocketClosed = errors.New("websocket connection closed")
+	// ErrInvalidWebSocketCloseCode is returned by Close for a code that cannot be sent in a close frame.
+	ErrInvalidWebSocketCloseCode = errors.New("invalid websocket close code")
+)
+
+type (
+	// WebSocketMessageType is the type of a WebSocket data message.
+	WebSocketMessageType int
+	// WebSocketCloseCode is the status code of a WebSocket close frame.
+	WebSocketCloseCode uint16
+
+	// WebSocketCloseError is returned by ReadMessage when the connection is closed by either side.
+	WebSocketCloseError struct {
+		Code   WebSocketCloseCode
+		Reason string
+	}
+
+	// WebSocketConn is a server side WebSocket connection.
+	// Reads and writes are safe for concurrent use, but only one goroutine may read at a time.
+	WebSocketConn interface {
+		// ReadMessage returns the next data message, answering pings and close frames in the meantime.
+		ReadMessage() (messageType WebSocketMessageType, payload []byte, err error)
+		// WriteMessage writes a single frame data message.
+		WriteMessage(messageType WebSocketMessageType, payload []byte) error
+		// Close sends the close frame and waits for the peer to answer if no other goroutine is reading.
+		// The codes reserved for the received close status, such as WebSocketCloseNoStatus and
+		// WebSocketCloseAbnormal, cannot be sent.
+		Close(code WebSocketCloseCode, reason string) error
+		// Subprotocol returns the negotiated subprotocol, or empty if none.
+		Subprotocol() string
+		// RemoteAddr returns the address of the client.
+		RemoteAddr() net.Addr
+		// UserValue returns the user value of the upgrade request, such as a route parameter.
+		UserValue(key string) any
+	}
+
+	// WebSocketUpgrader upgrades fasthttp requests to WebSocket connections.
+	WebSocketUpgrader interface {
+		// Upgrade validates the handshake and serves the connection with handler after the handler chain returns.
+		// A failed handshake panics with a HandledError, so the error middleware renders it.
+		// A panic of handler closes the connection, with WebSocketClosePolicyViolation for a client error HandledError
+		// and WebSocketCloseInternalError otherwise; the other errors are logged like WrapHandledError does.
+		Upgrade(ctx *fasthttp.RequestCtx, handler func(conn WebSocketConn))
+	}
+
+	webSocketUpgraderImpl struct {
+		checkOrigin    func(ctx *fasthttp.RequestCtx, origin string) bool
+		subprotocols   []string
+		maxMessageSize int64
+		pingInterval   time.Duration
+	}
+
+	webSocketConnImpl struct {
+		conn           net.Conn
+		reader         *bufio.Reader
+		subprotocol    string
+		userValues     map[string]any
+		maxMessageSize int64
+		pingInterval   time.Duration
+
+		readLock sync.Mutex
+		readErr  error
+
+		writeLock sync.Mutex
+		closeSent bool
+	}
+)
+
+func (e *WebSocketCloseError) Error() string {
+	return "websocket closed with " + strconv.Itoa(int(e.Code)) + " " + e.Reason
+}
+
+// NewWebSocketUpgrader returns a WebSocketUpgrader.
+// checkOrigin accepts the Origin header of the request; if nil, the origin must match the Host header
+// when present. The first of subprotocols that the client offers is negotiated.
+// maxMessageSize limits the size of a whole message, DefaultWebSocketMessageLimit if zero.
+// If pingInterval is positive, a ping is sent at that interval and a client silent for two intervals is dropped.
+func NewWebSocketUpgrader(
+	checkOrigin func(ctx *fasthttp.RequestCtx, origin string) bool,
+	subprotocols []string,
+	maxMessageSize int64,
+	pingInterval time.Duration,
+) WebSocketUpgrader {
+	if checkOrigin == nil {
+		checkOrigin = SameOrigin
+	}
+	if maxMessageSize <= 0 {
+		maxMessageSize = DefaultWebSocketMessageLimit
+	}
+	return &webSocketUpgraderImpl{
+		checkOrigin:    checkOrigin,
+		subprotocols:   subprotocols,
+		maxMessageSize: maxMessageSize,
+		pingInterval:   pingInterval,
+	}
+}
+
+// SameOrigin accepts requests without an Origin header and those whose origin host matches the Host header.
+func SameOrigin(ctx *fasthttp.RequestCtx, origin string) bool {
+	if len(origin) == 0 {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(parsed.Host, strutil.B2S(ctx.Host()))
+}
+
+// AllowOrigins returns an origin checker that accepts the listed origins, "*" accepts any origin.
+func AllowOrigins(origins ...string) func(ctx *fasthttp.RequestCtx, origin string) bool {
+	return func(ctx *fasthttp.RequestCtx, origin string) bool {
+		for _, allowed := range origins {
+			if allowed == "*" || strings.EqualFold(allowed, origin) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// headerHasToken reports whether the comma separated header value contains the token.
+func headerHasToken(value []byte, token string) bool {
+	for _, item := range strings.Split(strutil.B2S(value), ",") {
+		if strings.EqualFold(strings.TrimSpace(item), token) {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *webSocketUpgraderImpl) Upgrade(ctx *fasthttp.RequestCtx, handler func(conn WebSocketConn)) {
+	header := &ctx.Request.Header
+	if !ctx.IsGet() {
+		panic(HandledErrorMethodNotAllowed)
+	} else if !headerHasToken(header.Peek(ConnectionHeader), "upgrade") || !headerHasToken(header.Peek(UpgradeHeader), "websocket") {
+		panic(HandledErrorBadRequest)
+	} else if string(header.Peek(SecWebSocketVersionHeader)) != websocketVersion {
+		ctx.Response.Header.Set(SecWebSocketVersionHeader, websocketVersion)
+		panic(HandledErrorBadRequest)
+	} else if !u.checkOrigin(ctx, string(header.Peek(OriginHeader))) {
+		panic(HandledErrorForbidden)
+	}
+	key := header.Peek(SecWebSocketKeyHeader)
+	if decoded, err := base64.StdEncoding.DecodeString(strutil.B2S(key)); err != nil || len(decoded) != 16 {
+		panic(HandledErrorBadRequest)
+	}
+
+	var subprotocol string
+	for _, offered := range strings.Split(string(header.Peek(SecWebSocketProtocolHeader)), ",") {
+		if offered = strings.TrimSpace(offered); len(offered) > 0 && len(subprotocol) == 0 {
+			for _, supported := range u.subprotocols {
+				if offered == supported {
+					subprotocol = supported
+					break
+				}
+			}
+		}
+	}
+
+	// the request context is recycled before the hijack handler runs
+	userValues := make(map[string]any)
+	ctx.VisitUserValues(func(key []byte, value any) {
+		userValues[string(key)] = value
+	})
+
+	accept := sha1.Sum([]byte(string(key) + websocketGUID))
+	ctx.SetStatusCode(fasthttp.StatusSwitchingProtocols)
+	ctx.Response.Header.Set(UpgradeHeader, "websocket")
+	ctx.Response.Header.Set(ConnectionHeader, "Upgrade")
+	ctx.Response.Header.Set(SecWebSocketAcceptHeader, base64.StdEncoding.EncodeToString(accept[:]))
+	if len(subprotocol) > 0 {
+		ctx.Response.Header.Set(SecWebSocketProtocolHeader, subprotocol)
+	}
+	ctx.Hijack(func(conn net.Conn) {
+		c := &webSocketConnImpl{
+			conn:           conn,
+			reader:         bufio.NewReader(conn),
+			subprotocol:    subprotocol,
+			userValues:     userValues,
+			maxMessageSize: u.maxMessageSize,
+			pingInterval:   u.pingInterval,
+		}
+		done := make(chan struct{})
+		defer close(done)
+		if c.pingInterval > 0 {
+			go c.keepalive(done)
+		}
+		defer func() {
+			// the hijacked connection is out of reach of the error middleware, the panic closes the connection instead
+			if recovered := recover(); recovered != nil {
+				handled, err := WrapHandledError(recovered)
+				if err != nil {
+					log.Printf("websocket handler panic: %v", err)
+				}
+				code := WebSocketCloseInternalError
+				if handled.StatusCode() < 500 {
+					code = WebSocketClosePolicyViolation
+				}
+				_ = c.Close(code, handled.StatusMessage())
+				return
+			}
+			_ = c.Close(WebSocketCloseNormal, "")
+		}()
+		handler(c)
+	})
+}
+
+func (c *webSocketConnImpl) keepalive(done <-chan struct{}) {
+	ticker := time.NewTicker(c.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if c.writeFrame(websocketPing, nil) != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+func (c *webSocketConnImpl) Subprotocol() string      { return c.subprotocol }
+func (c *webSocketConnImpl) RemoteAddr() net.Addr     { return c.conn.RemoteAddr() }
+func (c *webSocketConnImpl) UserValue(key string) any { return c.userValues[key] }
+
+func (c *webSocketConnImpl) WriteMessage(messageType WebSocketMessageType, payload []byte) error {
+	if messageType != WebSocketTextMessage && messageType != WebSocketBinaryMessage {
+		return errors.New("invalid websocket message type " + strconv.Itoa(int(messageType)))
+	}
+	return c.writeFrame(byte(messageType), payload)
+}
+
+// writeFrame writes an unmasked final frame.
+func (c *webSocketConnImpl) writeFrame(opcode byte, payload []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if c.closeSent {
+		return ErrWebSocketClosed
+	} else if opcode == websocketClose {
+		c.closeSent = true
+	}
+
+	frame := make([]byte, 0, len(payload)+10)
+	frame = append(frame, 0x80|opcode)
+	switch length := len(payload); {
+	case length < 126:
+		frame = append(frame, byte(length))
+	case length <= 0xffff:
+		frame = append(frame, 126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(length))
+	default:
+		frame = append(frame, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(length))
+	}
+	frame = append(frame, payload...)
+
+	if err := c.conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout)); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// fail sends the close frame for a protocol violation and returns the matching error.
+func (c *webSocketConnImpl) fail(code WebSocketCloseCode, reason string) error {
+	_ = c.writeFrame(websocketClose, closePayload(code, reason))
+	return &WebSocketCloseError{Code: code, Reason: reason}
+}
+
+func closePayload(code WebSocketCloseCode, reason string) []byte {
+	if code == WebSocketCloseNoStatus {
+		return nil
+	}
+	// control frames carry at most 125 bytes
+	for len(reason) > 123 {
+		_, size := utf8.DecodeLastRuneInString(reason)
+		reason = reason[:len(reason)-size]
+	}
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return append(payload, reason...)
+}
+
+func validCloseCode(code WebSocketCloseCode) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	default:
+		return code >= 3000 && code < 5000
+	}
+}
+
+// readFrame reads a client frame, whose data payload must fit in limit bytes.
+func (c *webSocketConnImpl) readFrame(limit int64) (fin bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(c.reader, head[:]); err != nil {
+		return
+	}
+	fin, opcode = head[0]&0x80 != 0, head[0]&0x0f
+	length := int64(head[1] & 0x7f)
+	if head[0]&0x70 != 0 {
+		err = c.fail(WebSocketCloseProtocolError, "reserved bits set")
+		return
+	} else if head[1]&0x80 == 0 {
+		err = c.fail(WebSocketCloseProtocolError, "unmasked client frame")
+		return
+	}
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err = io.ReadFull(c.reader, extended[:]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err = io.ReadFull(c.reader, extended[:]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint64(extended[:]))
+	}
+	if opcode >= websocketClose && (!fin || length > 125) {
+		err = c.fail(WebSocketCloseProtocolError, "invalid control frame")
+		return
+	} else if opcode < websocketClose && (length < 0 || length > limit) {
+		err = c.fail(WebSocketCloseMessageTooBig, "message too big")
+		return
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i&3]
+	}
+	return
+}
+
+func (c *webSocketConnImpl) ReadMessage() (messageType WebSocketMessageType, message []byte, err error) {
+	c.readLock.Lock()
+	defer c.readLock.Unlock()
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+	defer func() {
+		if err != nil {
+			c.readErr = err
+		}
+	}()
+
+	for {
+		if c.pingInterval > 0 {
+			if err = c.conn.SetReadDeadline(time.Now().Add(2 * c.pingInterval)); err != nil {
+				return 0, nil, err
+			}
+		}
+		fin, opcode, payload, frameErr := c.readFrame(c.maxMessageSize - int64(len(message)))
+		if frameErr != nil {
+			return 0, nil, frameErr
+		}
+		switch opcode {
+		case websocketPing:
+			if err = c.writeFrame(websocketPong, payload); err != nil && err != ErrWebSocketClosed {
+				return 0, nil, err
+			}
+			continue
+		case websocketPong:
+			continue
+		case websocketClose:
+			return 0, nil, c.closeReceived(payload)
+		case byte(WebSocketTextMessage), byte(WebSocketBinaryMessage):
+			if messageType != 0 {
+				return 0, nil, c.fail(WebSocketCloseProtocolError, "unfinished fragmented message")
+			}
+			messageType = WebSocketMessageType(opcode)
+		case websocketContinuation:
+			if messageType == 0 {
+				return 0, nil, c.fail(WebSocketCloseProtocolError, "unexpected continuation frame")
+			}
+		default:
+			return 0, nil, c.fail(WebSocketCloseProtocolError, "unknown opcode "+strconv.Itoa(int(opcode)))
+		}
+
+		message = append(message, payload...)
+		if fin {
+			if messageType == WebSocketTextMessage && !utf8.Valid(message) {
+				return 0, nil, c.fail(WebSocketCloseInvalidPayload, "invalid utf-8 text")
+			}
+			return messageType, message, nil
+		}
+	}
+}
+
+// closeReceived answers the close frame of the client and returns its status.
+func (c *webSocketConnImpl) closeReceived(payload []byte) error {
+	closeErr := &WebSocketCloseError{Code: WebSocketCloseNoStatus}
+	if len(payload) == 1 {
+		return c.fail(WebSocketCloseProtocolError, "invalid close payload")
+	} else if len(payload) >= 2 {
+		closeErr.Code = WebSocketCloseCode(binary.BigEndian.Uint16(payload))
+		closeErr.Reason = string(payload[2:])
+		if !validCloseCode(closeErr.Code) {
+			return c.fail(WebSocketCloseProtocolError, "invalid close code")
+		} else if !utf8.ValidString(closeErr.Reason) {
+			return c.fail(WebSocketCloseInvalidPayload, "invalid utf-8 close reason")
+		}
+	}
+	_ = c.writeFrame(websocketClose, closePayload(closeErr.Code, ""))
+	return closeErr
+}
+
+func (c *webSocketConnImpl) Close(code WebSocketCloseCode, reason string) error {
+	if !validCloseCode(code) {
+		return ErrInvalidWebSocketCloseCode
+	} else if err := c.writeFrame(websocketClose, closePayload(code, reason)); err == ErrWebSocketClosed {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !c.readLock.TryLock() {
+		// the reading goroutine receives the answer
+		return nil
+	}
+	defer c.readLock.Unlock()
+	if c.readErr != nil {
+		return nil
+	}
+	c.readErr = &WebSocketCloseError{Code: code, Reason: reason}
+	if err := c.conn.SetReadDeadline(time.Now().Add(websocketCloseTimeout)); err != nil {
+		return err
+	}
+	for {
+		_, opcode, _, err := c.readFrame(c.maxMessageSize)
+		if err != nil || opcode == websocketClose {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package eighty
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// serveWebSocket serves the upgrader on an in-memory listener, echoing the messages until ReadMessage fails
+// and panicking on the "panic" message. The error that ends a connection is sent to readErrs.
+func serveWebSocket(t *testing.T, upgrader WebSocketUpgrader, readErrs chan<- error) *fasthttputil.InmemoryListener {
+	t.Helper()
+	ln := fasthttputil.NewInmemoryListener()
+	server := &fasthttp.Server{Handler: func(ctx *fasthttp.RequestCtx) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				handled, _ := WrapHandledError(recovered)
+				handled.RenderAPI(ctx, nil)
+			}
+		}()
+		ctx.SetUserValue("room", "lobby")
+		upgrader.Upgrade(ctx, func(conn WebSocketConn) {
+			if room := conn.UserValue("room"); room != "lobby" {
+				t.Errorf("user value %v, want %q", room, "lobby")
+			}
+			for {
+				messageType, payload, err := conn.ReadMessage()
+				if err != nil {
+					readErrs <- err
+					return
+				} else if string(payload) == "panic" {
+					panic(HandledErrorForbidden)
+				} else if err = conn.WriteMessage(messageType, payload); err != nil {
+					readErrs <- err
+					return
+				}
+			}
+		})
+	}}
+	go func() { _ = server.Serve(ln) }()
+	t.Cleanup(func() { _ = ln.Close() })
+	return ln
+}
+
+// dialWebSocket sends the handshake request with the headers and returns the connection and the response.
+func dialWebSocket(t *testing.T, ln *fasthttputil.InmemoryListener, headers map[string]string) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+	conn, err := ln.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	req, err := http.NewRequest(http.MethodGet, "http://chat.example.com/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(UpgradeHeader, "websocket")
+	req.Header.Set(ConnectionHeader, "keep-alive, Upgrade")
+	req.Header.Set(SecWebSocketVersionHeader, websocketVersion)
+	req.Header.Set(SecWebSocketKeyHeader, "dGhlIHNhbXBsZSBub25jZQ==")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	if err = req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, reader, res
+}
+
+// writeClientFrame writes a masked frame.
+func writeClientFrame(t *testing.T, conn net.Conn, fin bool, opcode byte, payload []byte) {
+	t.Helper()
+	head := opcode
+	if fin {
+		head |= 0x80
+	}
+	frame := []byte{head}
+	switch length := len(payload); {
+	case length < 126:
+		frame = append(frame, 0x80|byte(length))
+	default:
+		frame = append(frame, 0x80|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(length))
+	}
+	mask := [4]byte{1, 2, 3, 4}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i&3])
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readServerFrame reads an unmasked frame of less than 126 bytes.
+func readServerFrame(t *testing.T, reader *bufio.Reader) (opcode byte, payload []byte) {
+	t.Helper()
+	var head [2]byte
+	if _, err := reader.Read(head[:1]); err != nil {
+		t.Fatal(err)
+	} else if head[1], err = reader.ReadByte(); err != nil {
+		t.Fatal(err)
+	}
+	if head[0]&0x80 == 0 || head[1]&0x80 != 0 || head[1] >= 126 {
+		t.Fatalf("unexpected frame header % x", head)
+	}
+	payload = make([]byte, head[1])
+	for read := 0; read < len(payload); {
+		n, err := reader.Read(payload[read:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		read += n
+	}
+	return head[0] & 0x0f, payload
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	ln := serveWebSocket(t, NewWebSocketUpgrader(nil, []string{"chat.v2", "chat.v1"}, 16, 0), make(chan error, 16))
+	tests := []struct {
+		name            string
+		headers         map[string]string
+		wantStatus      int
+		wantSubprotocol string
+	}{
+		{name: "accepted", headers: map[string]string{"Origin": "http://chat.example.com", SecWebSocketProtocolHeader: "chat.v1, chat.v2"},
+			wantStatus: http.StatusSwitchingProtocols, wantSubprotocol: "chat.v1"},
+		{name: "no origin", wantStatus: http.StatusSwitchingProtocols},
+		{name: "cross origin", headers: map[string]string{"Origin": "http://evil.example.org"}, wantStatus: http.StatusForbidden},
+		{name: "other version", headers: map[string]string{SecWebSocketVersionHeader: "8"}, wantStatus: http.StatusBadRequest},
+		{name: "short key", headers: map[string]string{SecWebSocketKeyHeader: "c2hvcnQ="}, wantStatus: http.StatusBadRequest},
+		{name: "not upgrading", headers: map[string]string{UpgradeHeader: "h2c"}, wantStatus: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, res := dialWebSocket(t, ln, test.headers)
+			if res.StatusCode != test.wantStatus {
+				t.Fatalf("status %d, want %d", res.StatusCode, test.wantStatus)
+			} else if test.wantStatus != http.StatusSwitchingProtocols {
+				return
+			}
+			if accept := res.Header.Get(SecWebSocketAcceptHeader); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+				t.Errorf("accept key %q", accept)
+			} else if subprotocol := res.Header.Get(SecWebSocketProtocolHeader); subprotocol != test.wantSubprotocol {
+				t.Errorf("subprotocol %q, want %q", subprotocol, test.wantSubprotocol)
+			}
+		})
+	}
+}
+
+func TestWebSocketMessages(t *testing.T) {
+	// the failing connections leave their read errors behind
+	readErrs := make(chan error, 16)
+	ln := serveWebSocket(t, NewWebSocketUpgrader(nil, nil, 16, 0), readErrs)
+
+	t.Run("echo and close", func(t *testing.T) {
+		conn, reader, _ := dialWebSocket(t, ln, nil)
+		writeClientFrame(t, conn, false, byte(WebSocketTextMessage), []byte("hel"))
+		writeClientFrame(t, conn, true, websocketPing, []byte("ping"))
+		writeClientFrame(t, conn, true, websocketContinuation, []byte("lo"))
+		if opcode, payload := readServerFrame(t, reader); opcode != websocketPong || string(payload) != "ping" {
+			t.Fatalf("got frame %d %q, want the pong", opcode, payload)
+		} else if opcode, payload = readServerFrame(t, reader); opcode != byte(WebSocketTextMessage) || string(payload) != "hello" {
+			t.Fatalf("got frame %d %q, want the echo", opcode, payload)
+		}
+
+		writeClientFrame(t, conn, true, websocketClose, closePayload(WebSocketCloseGoingAway, "bye"))
+		if opcode, payload := readServerFrame(t, reader); opcode != websocketClose || binary.BigEndian.Uint16(payload) != uint16(WebSocketCloseGoingAway) {
+			t.Fatalf("got frame %d % x, want the close answer", opcode, payload)
+		}
+		var closeErr *WebSocketCloseError
+		if err := <-readErrs; !errors.As(err, &closeErr) || closeErr.Code != WebSocketCloseGoingAway || closeErr.Reason != "bye" {
+			t.Fatalf("read error %v, want the close of the client", err)
+		}
+	})
+
+	tests := []struct {
+		name     string
+		frames   func(t *testing.T, conn net.Conn)
+		wantCode WebSocketCloseCode
+	}{
+		{name: "message too big", frames: func(t *testing.T, conn net.Conn) {
+			writeClientFrame(t, conn, true, byte(WebSocketBinaryMessage), make([]byte, 17))
+		}, wantCode: WebSocketCloseMessageTooBig},
+		{name: "fragments too big", frames: func(t *testing.T, conn net.Conn) {
+			writeClientFrame(t, conn, false, byte(WebSocketBinaryMessage), make([]byte, 10))
+			writeClientFrame(t, conn, true, websocketContinuation, make([]byte, 10))
+		}, wantCode: WebSocketCloseMessageTooBig},
+		{name: "invalid utf-8", frames: func(t *testing.T, conn net.Conn) {
+			writeClientFrame(t, conn, true, byte(WebSocketTextMessage), []byte{0xff, 0xfe})
+		}, wantCode: WebSocketCloseInvalidPayload},
+		{name: "unexpected continuation", frames: func(t *testing.T, conn net.Conn) {
+			writeClientFrame(t, conn, true, websocketContinuation, []byte("x"))
+		}, wantCode: WebSocketCloseProtocolError},
+		{name: "reserved close code", frames: func(t *testing.T, conn net.Conn) {
+			writeClientFrame(t, conn, true, websocketClose, closePayload(WebSocketCloseAbnormal, ""))
+		}, wantCode: WebSocketCloseProtocolError},
+		{name: "handler panic", frames: func(t *testing.T, conn net.Conn) {
+			writeClientFrame(t, conn, true, byte(WebSocketTextMessage), []byte("panic"))
+		}, wantCode: WebSocketClosePolicyViolation},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, reader, _ := dialWebSocket(t, ln, nil)
+			test.frames(t, conn)
+			if opcode, payload := readServerFrame(t, reader); opcode != websocketClose || len(payload) < 2 {
+				t.Fatalf("got frame %d % x, want a close frame", opcode, payload)
+			} else if code := WebSocketCloseCode(binary.BigEndian.Uint16(payload)); code != test.wantCode {
+				t.Fatalf("close code %d, want %d", code, test.wantCode)
+			}
+		})
+	}
+}
+
+func TestWebSocketCloseCodes(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	c := &webSocketConnImpl{conn: server, reader: bufio.NewReader(server), maxMessageSize: 16}
+	for _, code := range []WebSocketCloseCode{WebSocketCloseNoStatus, WebSocketCloseAbnormal, 1015, 999} {
+		if err := c.Close(code, ""); err != ErrInvalidWebSocketCloseCode {
+			t.Errorf("close with %d returned %v, want %v", code, err, ErrInvalidWebSocketCloseCode)
+		}
+	}
+	if c.closeSent {
+		t.Fatal("a close frame was sent with a reserved code")
+	}
+}